@@ -0,0 +1,98 @@
+package pgdevserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/mholt/archives"
+)
+
+// bundleFormat is the format of bundles written by Export and read by Import.
+var bundleFormat = archives.CompressedArchive{
+	Compression: archives.Zstd{},
+	Archival:    archives.Tar{},
+	Extraction:  archives.Tar{},
+}
+
+// Export writes an installed version of postgres to w as a zstd compressed tar bundle that can be installed on
+// another machine with Import. It is an error if the version is not installed.
+func (m *PGManager) Export(ctx context.Context, version string, w io.Writer) (errOut error) {
+	m.init()
+	cacheDir, unlock, err := m.cache.Dir(pgCacheKey(version), pgmValidateCache, nil)
+	if err != nil {
+		return fmt.Errorf("version %s is not installed: %w", version, err)
+	}
+	defer func() { errOut = errors.Join(errOut, unlock()) }()
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return err
+	}
+	filenames := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		filenames[filepath.Join(cacheDir, entry.Name())] = entry.Name()
+	}
+	files, err := archives.FilesFromDisk(ctx, nil, filenames)
+	if err != nil {
+		return err
+	}
+	return bundleFormat.Archive(ctx, w, files)
+}
+
+// Import installs postgres from a bundle written by Export and returns its version. Nothing is downloaded, so it
+// works without network access. Importing a version that is already installed leaves the installed copy in place.
+func (m *PGManager) Import(ctx context.Context, r io.Reader) (_ string, errOut error) {
+	m.init()
+	err := os.MkdirAll(m.config.CacheDir, 0o700)
+	if err != nil {
+		return "", err
+	}
+	// Extract next to the cache entries so they can be moved into place. Dot files are ignored by the cache.
+	tmpDir, err := os.MkdirTemp(m.config.CacheDir, ".import-")
+	if err != nil {
+		return "", err
+	}
+	defer func() { errOut = errors.Join(errOut, os.RemoveAll(tmpDir)) }()
+	err = bundleFormat.Extract(ctx, r, func(_ context.Context, info archives.FileInfo) error {
+		return handleTxzExtractFile(info, tmpDir)
+	})
+	if err != nil {
+		return "", fmt.Errorf("extracting bundle: %w", err)
+	}
+	b, err := os.ReadFile(versionFile(tmpDir))
+	if err != nil {
+		return "", fmt.Errorf("bundle has no version: %w", err)
+	}
+	version := strings.TrimSpace(string(b))
+	_, err = semver.NewVersion(version)
+	if err != nil {
+		return "", fmt.Errorf("bundle has invalid version %q: %w", version, err)
+	}
+	err = pgmValidateCache(tmpDir)
+	if err != nil {
+		return "", fmt.Errorf("bundle is not a postgres installation: %w", err)
+	}
+	populator := func(cacheDir string) error {
+		entries, err := os.ReadDir(tmpDir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			err = os.Rename(filepath.Join(tmpDir, entry.Name()), filepath.Join(cacheDir, entry.Name()))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	_, unlock, err := m.cache.Dir(pgCacheKey(version), pgmValidateCache, populator)
+	if err != nil {
+		return "", err
+	}
+	return version, unlock()
+}
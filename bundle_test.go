@@ -0,0 +1,127 @@
+package pgdevserver
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_Export(t *testing.T) {
+	src := testMgr(t, t.TempDir())
+	src.init()
+	version := "17.2.0"
+	dir := filepath.Join(src.config.CacheDir, pgCacheKey(version))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "bin"), 0o700))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "lib"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bin", "pg_ctl"), []byte("pg_ctl"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib", "libpq.so.5.17"), []byte("libpq"), 0o600))
+	require.NoError(t, os.Symlink("libpq.so.5.17", filepath.Join(dir, "lib", "libpq.so.5")))
+	require.NoError(t, os.WriteFile(versionFile(dir), []byte(version+"\n"), 0o600))
+
+	var bundle bytes.Buffer
+	require.NoError(t, src.Export(t.Context(), version, &bundle))
+	require.Error(t, src.Export(t.Context(), "16.4.0", &bytes.Buffer{}))
+
+	dest := testMgr(t, t.TempDir())
+	got, err := dest.Import(t.Context(), bytes.NewReader(bundle.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, version, got)
+	versions, err := dest.InstalledVersions()
+	require.NoError(t, err)
+	require.Equal(t, []string{version}, versions)
+	bin, unlock, err := dest.Bin(t.Context(), version)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, unlock()) })
+	b, err := os.ReadFile(filepath.Join(bin, "pg_ctl"))
+	require.NoError(t, err)
+	require.Equal(t, "pg_ctl", string(b))
+	info, err := os.Stat(filepath.Join(bin, "pg_ctl"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+	link, err := os.Readlink(filepath.Join(bin, "..", "lib", "libpq.so.5"))
+	require.NoError(t, err)
+	require.Equal(t, "libpq.so.5.17", link)
+
+	// importing again keeps the installed copy
+	_, err = dest.Import(t.Context(), bytes.NewReader(bundle.Bytes()))
+	require.NoError(t, err)
+}
+
+func TestManager_Import_unsafe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on windows")
+	}
+	type entry struct {
+		name, link, content string
+	}
+	bundle := func(t *testing.T, entries ...entry) []byte {
+		t.Helper()
+		var buf bytes.Buffer
+		zw, err := bundleFormat.Compression.OpenWriter(&buf)
+		require.NoError(t, err)
+		tw := tar.NewWriter(zw)
+		for _, e := range entries {
+			hdr := &tar.Header{Name: e.name, Mode: 0o600, Typeflag: tar.TypeReg, Size: int64(len(e.content))}
+			if e.link != "" {
+				hdr = &tar.Header{Name: e.name, Linkname: e.link, Mode: 0o777, Typeflag: tar.TypeSymlink}
+			}
+			require.NoError(t, tw.WriteHeader(hdr))
+			_, err = tw.Write([]byte(e.content))
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+		require.NoError(t, zw.Close())
+		return buf.Bytes()
+	}
+
+	for _, tc := range []struct {
+		name    string
+		entries []entry
+	}{
+		{name: "absolute target", entries: []entry{{name: "lib/libpq.so", link: "/etc/passwd"}}},
+		{name: "escaping target", entries: []entry{{name: "lib/libpq.so", link: "../../../../outside"}}},
+		{
+			name: "write through symlink",
+			entries: []entry{
+				{name: "lib", link: "."},
+				{name: "lib/pwned", content: "pwned"},
+			},
+		},
+		{
+			name: "overwrite symlink",
+			entries: []entry{
+				{name: "bin/pg_ctl", link: "pg_ctl.real"},
+				{name: "bin/pg_ctl", content: "pwned"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := testMgr(t, t.TempDir())
+			entries := append(tc.entries,
+				entry{name: "bin/pg_ctl.real", content: "pg_ctl"},
+				entry{name: "version.txt", content: "17.2.0\n"},
+			)
+			_, err := m.Import(t.Context(), bytes.NewReader(bundle(t, entries...)))
+			require.ErrorContains(t, err, "illegal")
+			versions, err := m.InstalledVersions()
+			require.NoError(t, err)
+			require.Empty(t, versions)
+		})
+	}
+
+	t.Run("relative target inside the bundle", func(t *testing.T) {
+		m := testMgr(t, t.TempDir())
+		_, err := m.Import(t.Context(), bytes.NewReader(bundle(t,
+			entry{name: "bin/pg_ctl", content: "pg_ctl"},
+			entry{name: "lib/libpq.so.5.17", content: "libpq"},
+			entry{name: "bin/libpq.so", link: "../lib/libpq.so.5.17"},
+			entry{name: "version.txt", content: "17.2.0\n"},
+		)))
+		require.NoError(t, err)
+	})
+}
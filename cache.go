@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/willabides/pgdevserver/internal/bdcache"
 )
@@ -47,6 +48,34 @@ func configJSONPath(cacheDir string) string {
 	return filepath.Join(cacheDir, "config", "config.json")
 }
 
+func createdAtPath(cacheDir string) string {
+	return filepath.Join(cacheDir, "config", "created_at")
+}
+
+func lastStartedPath(cacheDir string) string {
+	return filepath.Join(cacheDir, "config", "last_started")
+}
+
+func writeTimestamp(filename string, t time.Time) error {
+	err := os.MkdirAll(filepath.Dir(filename), 0o700)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, []byte(t.UTC().Format(time.RFC3339)), 0o600)
+}
+
+// readTimestamp reads a timestamp written by writeTimestamp. It returns the zero time if the file does not exist.
+func readTimestamp(filename string) (time.Time, error) {
+	b, err := os.ReadFile(filename)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return time.Time{}, nil
+	case err != nil:
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, strings.TrimSpace(string(b)))
+}
+
 // ServersFromCache returns all the servers in the cache.
 func ServersFromCache(cacheDir string) ([]*Server, error) {
 	serverCache := bdcache.Cache{Root: filepath.Join(cacheDir, "server")}
@@ -65,6 +94,36 @@ func ServersFromCache(cacheDir string) ([]*Server, error) {
 	return servers, nil
 }
 
+// existingVersions returns the postgres versions of the servers in the cache that have the same settings as s
+// apart from the version.
+func (s *Server) existingVersions() ([]string, error) {
+	var versions []string
+	err := s.cache.Walk(func(serverCacheDir string) error {
+		configJSON, err := os.ReadFile(configJSONPath(serverCacheDir))
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			return nil
+		case err != nil:
+			return err
+		}
+		var existing Config
+		err = json.Unmarshal(configJSON, &existing)
+		if err != nil {
+			return err
+		}
+		cfg := s.config.clone()
+		cfg.PostgresVersion = existing.PostgresVersion
+		if cfg.cacheKey() == filepath.Base(serverCacheDir) {
+			versions = append(versions, existing.PostgresVersion)
+		}
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return versions, err
+}
+
 // ServerFromCache returns a server from the cache by ID.
 func ServerFromCache(rootCache, id string) (_ *Server, errOut error) {
 	serverCache := bdcache.Cache{Root: filepath.Join(rootCache, "server")}
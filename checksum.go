@@ -0,0 +1,102 @@
+package pgdevserver
+
+import (
+	"context"
+	"crypto/sha1" //nolint:gosec // sha1 is only used when a maven repository publishes nothing stronger
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// checksumAlgorithms are the checksum sidecar files maven repositories publish, strongest first.
+var checksumAlgorithms = []struct {
+	name    string
+	newHash func() hash.Hash
+}{
+	{name: "sha512", newHash: sha512.New},
+	{name: "sha256", newHash: sha256.New},
+	{name: "sha1", newHash: sha1.New},
+}
+
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// checksumFile is where the verified digest of the downloaded jar is recorded.
+func checksumFile(cacheDir string) string {
+	return filepath.Join(cacheDir, "checksum.txt")
+}
+
+// verifyChecksum verifies filename against the strongest checksum sidecar published for jarURL and returns the
+// verified digest formatted as algorithm:hex. It is an error if no sidecar is published.
+func (m *PGManager) verifyChecksum(ctx context.Context, jarURL, filename string) (string, error) {
+	for _, algo := range checksumAlgorithms {
+		want, err := m.fetchChecksum(ctx, jarURL+"."+algo.name)
+		if err != nil {
+			return "", fmt.Errorf("fetching %s checksum: %w", algo.name, err)
+		}
+		if want == "" {
+			continue
+		}
+		got, err := fileDigest(filename, algo.newHash())
+		if err != nil {
+			return "", err
+		}
+		if got != want {
+			return "", fmt.Errorf("%w for %s: expected %s %s, got %s", errChecksumMismatch, jarURL, algo.name, want, got)
+		}
+		return algo.name + ":" + got, nil
+	}
+	return "", fmt.Errorf("no checksum published for %s", jarURL)
+}
+
+// fetchChecksum returns the hex digest in the sidecar file at u or "" if it does not exist.
+func (m *PGManager) fetchChecksum(ctx context.Context, u string) (_ string, errOut error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
+	if err != nil {
+		return "", err
+	}
+	resp, err := m.config.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { errOut = errors.Join(errOut, resp.Body.Close()) }()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", nil
+	default:
+		return "", &httpStatusError{StatusCode: resp.StatusCode}
+	}
+	// sidecars are small, but don't trust the server to keep them that way
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", err
+	}
+	// some repositories follow the digest with the file name like sha256sum does
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return "", errors.New("empty checksum file")
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+// fileDigest returns the hex encoded digest of filename using h.
+func fileDigest(filename string, h hash.Hash) (_ string, errOut error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer func() { errOut = errors.Join(errOut, f.Close()) }()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,73 @@
+package pgdevserver
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPGManager_verifyChecksum(t *testing.T) {
+	content := []byte("jar content")
+	jarFile := filepath.Join(t.TempDir(), "pg.jar")
+	require.NoError(t, os.WriteFile(jarFile, content, 0o600))
+	tampered := filepath.Join(t.TempDir(), "tampered.jar")
+	require.NoError(t, os.WriteFile(tampered, []byte("tampered"), 0o600))
+	sha1Sum := sha1.Sum(content)
+	sha256Sum := sha256.Sum256(content)
+	sha1Hex := hex.EncodeToString(sha1Sum[:])
+	sha256Hex := hex.EncodeToString(sha256Sum[:])
+
+	newMgr := func(t *testing.T, sidecars map[string]string) (*PGManager, string) {
+		t.Helper()
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, ok := sidecars[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			_, err := w.Write([]byte(body))
+			assert.NoError(t, err)
+		}))
+		t.Cleanup(srv.Close)
+		mgr := testMgr(t, t.TempDir())
+		mgr.init()
+		return mgr, srv.URL + "/pg.jar"
+	}
+
+	t.Run("strongest available", func(t *testing.T) {
+		mgr, u := newMgr(t, map[string]string{
+			"/pg.jar.sha1":   sha1Hex,
+			"/pg.jar.sha256": sha256Hex + "  pg.jar\n",
+		})
+		digest, err := mgr.verifyChecksum(t.Context(), u, jarFile)
+		require.NoError(t, err)
+		require.Equal(t, "sha256:"+sha256Hex, digest)
+	})
+
+	t.Run("sha1 only", func(t *testing.T) {
+		mgr, u := newMgr(t, map[string]string{"/pg.jar.sha1": sha1Hex + "\n"})
+		digest, err := mgr.verifyChecksum(t.Context(), u, jarFile)
+		require.NoError(t, err)
+		require.Equal(t, "sha1:"+sha1Hex, digest)
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		mgr, u := newMgr(t, map[string]string{"/pg.jar.sha256": sha256Hex})
+		_, err := mgr.verifyChecksum(t.Context(), u, tampered)
+		require.ErrorContains(t, err, "checksum mismatch")
+	})
+
+	t.Run("no sidecars", func(t *testing.T) {
+		mgr, u := newMgr(t, nil)
+		_, err := mgr.verifyChecksum(t.Context(), u, jarFile)
+		require.ErrorContains(t, err, "no checksum published")
+	})
+}
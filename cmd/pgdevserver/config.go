@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/alecthomas/kong"
+	"gopkg.in/yaml.v3"
+)
+
+// projectConfigNames are the files findProjectConfig looks for in each directory, in order of preference.
+var projectConfigNames = []string{"pgdevserver.yaml", ".pgdevserver.toml"}
+
+// findProjectConfig walks up from dir looking for a project config file. It returns "" when there is none.
+func findProjectConfig(dir string) (string, error) {
+	for {
+		for _, name := range projectConfigNames {
+			filename := filepath.Join(dir, name)
+			_, err := os.Stat(filename)
+			switch {
+			case err == nil:
+				return filename, nil
+			case !errors.Is(err, os.ErrNotExist):
+				return "", err
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+func loadProjectConfig(filename string) (map[string]any, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]any{}
+	if strings.HasSuffix(filename, ".toml") {
+		err = toml.Unmarshal(b, &values)
+	} else {
+		err = yaml.Unmarshal(b, &values)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return values, nil
+}
+
+// projectConfigResolver resolves flag values from a project config file. Keys are flag names with dashes or
+// underscores. Relative paths are relative to the config file. Flags whose environment variable is set are left
+// alone so that PGDEVSERVER_* variables take precedence over the file.
+func projectConfigResolver(filename string) (kong.Resolver, error) {
+	values, err := loadProjectConfig(filename)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(filename)
+	var resolver kong.ResolverFunc = func(_ *kong.Context, _ *kong.Path, flag *kong.Flag) (any, error) {
+		for _, env := range flag.Envs {
+			if _, ok := os.LookupEnv(env); ok {
+				return nil, nil
+			}
+		}
+		raw, ok := values[strings.ReplaceAll(flag.Name, "-", "_")]
+		if !ok {
+			raw, ok = values[flag.Name]
+		}
+		if !ok {
+			return nil, nil
+		}
+		raw, err := normalizeConfigValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", filename, flag.Name, err)
+		}
+		s, isString := raw.(string)
+		if isString && isPathFlag(flag) && !filepath.IsAbs(s) && !strings.HasPrefix(s, "~") {
+			return filepath.Join(dir, s), nil
+		}
+		return raw, nil
+	}
+	return resolver, nil
+}
+
+// normalizeConfigValue converts integers to strings so that values like port: 5432 work with string flags. Floats
+// are rejected because their original text is lost. pg: 16.10 would become 16.1, which is a different version.
+func normalizeConfigValue(raw any) (any, error) {
+	switch v := raw.(type) {
+	case string, bool:
+		return v, nil
+	case float32, float64:
+		return nil, fmt.Errorf("%v is read as a number; quote it to use it as a string", v)
+	case []any:
+		out := make([]any, len(v))
+		for i := range v {
+			var err error
+			out[i], err = normalizeConfigValue(v[i])
+			if err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+func isPathFlag(flag *kong.Flag) bool {
+	switch flag.Tag.Type {
+	case "path", "existingdir", "existingfile":
+		return true
+	default:
+		return false
+	}
+}
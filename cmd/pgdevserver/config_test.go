@@ -0,0 +1,173 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_findProjectConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		files []string
+		dir   string
+		want  string
+	}{
+		{
+			name: "none",
+			dir:  "a/b",
+		},
+		{
+			name:  "same directory",
+			files: []string{"a/b/pgdevserver.yaml"},
+			dir:   "a/b",
+			want:  "a/b/pgdevserver.yaml",
+		},
+		{
+			name:  "parent directory",
+			files: []string{"a/.pgdevserver.toml"},
+			dir:   "a/b",
+			want:  "a/.pgdevserver.toml",
+		},
+		{
+			name:  "nearest wins",
+			files: []string{"a/pgdevserver.yaml", "a/b/.pgdevserver.toml"},
+			dir:   "a/b",
+			want:  "a/b/.pgdevserver.toml",
+		},
+		{
+			name:  "yaml preferred over toml",
+			files: []string{"a/b/pgdevserver.yaml", "a/b/.pgdevserver.toml"},
+			dir:   "a/b",
+			want:  "a/b/pgdevserver.yaml",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			require.NoError(t, os.MkdirAll(filepath.Join(root, tc.dir), 0o700))
+			for _, file := range tc.files {
+				require.NoError(t, os.WriteFile(filepath.Join(root, file), nil, 0o600))
+			}
+			got, err := findProjectConfig(filepath.Join(root, tc.dir))
+			require.NoError(t, err)
+			want := ""
+			if tc.want != "" {
+				want = filepath.Join(root, tc.want)
+			}
+			require.Equal(t, want, got)
+		})
+	}
+}
+
+func Test_normalizeConfigValue(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		raw     any
+		want    any
+		wantErr bool
+	}{
+		{name: "string", raw: "16.10", want: "16.10"},
+		{name: "bool", raw: true, want: true},
+		{name: "int", raw: 5432, want: "5432"},
+		{name: "int64", raw: int64(5432), want: "5432"},
+		{name: "float", raw: 16.10, wantErr: true},
+		{name: "list", raw: []any{"a", 1}, want: []any{"a", "1"}},
+		{name: "list with float", raw: []any{"a", 17.0}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := normalizeConfigValue(tc.raw)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func Test_projectConfigResolver(t *testing.T) {
+	type cli struct {
+		PG          string   `kong:"name='pg',env='PGDEVSERVER_PG'"`
+		Port        string   `kong:"name='port'"`
+		InitScripts string   `kong:"name='init-scripts',type='path'"`
+		Option      []string `kong:"name='option'"`
+	}
+	parse := func(t *testing.T, config string, args ...string) (cli, error) {
+		t.Helper()
+		dir := t.TempDir()
+		filename := filepath.Join(dir, "pgdevserver.yaml")
+		require.NoError(t, os.WriteFile(filename, []byte(config), 0o600))
+		resolver, err := projectConfigResolver(filename)
+		require.NoError(t, err)
+		var c cli
+		parser, err := kong.New(&c, kong.Resolvers(resolver))
+		require.NoError(t, err)
+		_, err = parser.Parse(args)
+		return c, err
+	}
+
+	t.Run("file values", func(t *testing.T) {
+		c, err := parse(t, "pg: '16.10'\nport: 5432\ninit_scripts: scripts\noption: [a, b]\n")
+		require.NoError(t, err)
+		require.Equal(t, "16.10", c.PG)
+		require.Equal(t, "5432", c.Port)
+		require.True(t, filepath.IsAbs(c.InitScripts))
+		require.Equal(t, "scripts", filepath.Base(c.InitScripts))
+		require.Equal(t, []string{"a", "b"}, c.Option)
+	})
+
+	t.Run("dashed keys", func(t *testing.T) {
+		c, err := parse(t, "init-scripts: /scripts\n")
+		require.NoError(t, err)
+		require.Equal(t, "/scripts", c.InitScripts)
+	})
+
+	t.Run("env over file", func(t *testing.T) {
+		t.Setenv("PGDEVSERVER_PG", "17")
+		c, err := parse(t, "pg: '16'\nport: 5432\n")
+		require.NoError(t, err)
+		require.Equal(t, "17", c.PG)
+		require.Equal(t, "5432", c.Port)
+	})
+
+	t.Run("flags over file", func(t *testing.T) {
+		c, err := parse(t, "pg: '16'\n", "--pg", "15")
+		require.NoError(t, err)
+		require.Equal(t, "15", c.PG)
+	})
+
+	t.Run("float", func(t *testing.T) {
+		_, err := parse(t, "pg: 16.10\n")
+		require.ErrorContains(t, err, "quote it")
+	})
+}
+
+func Test_rootCmd_envars(t *testing.T) {
+	t.Setenv("PGDEVSERVER_PG", "16")
+	t.Setenv("PGDEVSERVER_CACHE", "/cache")
+	// only server options and the cache have environment variables
+	t.Setenv("PGDEVSERVER_FORMAT", "json")
+	t.Setenv("PGDEVSERVER_RM", "true")
+	t.Setenv("PGDEVSERVER_FORCE", "true")
+	var c rootCmd
+	parser, err := kong.New(&c, help)
+	require.NoError(t, err)
+
+	_, err = parser.Parse([]string{"exec", "--", "true"})
+	require.NoError(t, err)
+	require.Equal(t, "16", c.ServerCmds.Exec.ServerParams.PostgresVersion)
+	require.Equal(t, "/cache", c.ServerCmds.Exec.CacheParams.Cache)
+	require.False(t, c.ServerCmds.Exec.Rm)
+
+	_, err = parser.Parse([]string{"list"})
+	require.NoError(t, err)
+	require.Equal(t, "table", c.ServerCmds.List.FormatParams.Format)
+
+	_, err = parser.Parse([]string{"rm", "--id", "default-0123456789abcdef0123"})
+	require.NoError(t, err)
+	require.False(t, c.ServerCmds.Rm.Force)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+type envCmd struct {
+	ServerParams serverParams `kong:"embed,group='Server Options'"`
+	CacheParams  cacheParams  `kong:"embed"`
+	Shell        string       `kong:"default='bash',enum='bash,zsh,fish,dotenv,github',help='Output syntax. One of bash, zsh, fish, dotenv or github.'"`
+	Start        bool         `kong:"help='Start the server if it is not already running.'"`
+}
+
+func (c *envCmd) Run() error {
+	ctx := context.Background()
+	srv, err := c.ServerParams.server(c.CacheParams)
+	if err != nil {
+		return err
+	}
+	if c.Start {
+		err = srv.Start(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	env, err := srv.Env(ctx)
+	if err != nil {
+		return err
+	}
+	return writeEnv(os.Stdout, c.Shell, env)
+}
+
+// writeEnv writes env to w in the syntax for shell. github is the format for appending to $GITHUB_ENV in GitHub
+// Actions.
+func writeEnv(w io.Writer, shell string, env map[string]string) error {
+	for _, k := range slices.Sorted(maps.Keys(env)) {
+		v := env[k]
+		var line string
+		switch shell {
+		case "bash", "zsh":
+			line = fmt.Sprintf("export %s=%s", k, shellQuote(v))
+		case "fish":
+			line = fmt.Sprintf("set -gx %s %s;", k, fishQuote(v))
+		case "dotenv":
+			line = fmt.Sprintf("%s=%s", k, dotenvQuote(v))
+		case "github":
+			line = k + "=" + v
+			if strings.Contains(v, "\n") {
+				// the delimiter can't appear in the value or the value would end early
+				delim := "PGDEVSERVER_EOF"
+				for i := 1; strings.Contains(v, delim); i++ {
+					delim = fmt.Sprintf("PGDEVSERVER_EOF_%d", i)
+				}
+				line = fmt.Sprintf("%s<<%s\n%s\n%s", k, delim, v, delim)
+			}
+		default:
+			return fmt.Errorf("unsupported shell %q", shell)
+		}
+		_, err := fmt.Fprintln(w, line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fishQuote quotes s for fish, where backslashes and single quotes are escaped inside single quotes.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// dotenvQuote double-quotes s, escaping characters that dotenv parsers interpret inside double quotes.
+func dotenvQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`).Replace(s) + `"`
+}
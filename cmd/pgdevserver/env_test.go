@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_writeEnv(t *testing.T) {
+	env := map[string]string{
+		"PGHOST":     "/tmp/my socket",
+		"PGPASSWORD": `it's $HOME "quoted" \n`,
+		"PGUSER":     "postgres",
+	}
+	for _, tc := range []struct {
+		shell string
+		env   map[string]string
+		want  string
+	}{
+		{
+			shell: "bash",
+			env:   env,
+			want: "" +
+				"export PGHOST='/tmp/my socket'\n" +
+				`export PGPASSWORD='it'\''s $HOME "quoted" \n'` + "\n" +
+				"export PGUSER='postgres'\n",
+		},
+		{
+			shell: "zsh",
+			env:   map[string]string{"PGPASSWORD": "line1\nline2"},
+			want:  "export PGPASSWORD='line1\nline2'\n",
+		},
+		{
+			shell: "fish",
+			env:   env,
+			want: "" +
+				"set -gx PGHOST '/tmp/my socket';\n" +
+				`set -gx PGPASSWORD 'it\'s $HOME "quoted" \\n';` + "\n" +
+				"set -gx PGUSER 'postgres';\n",
+		},
+		{
+			shell: "fish",
+			env:   map[string]string{"PGPASSWORD": "line1\nline2"},
+			want:  "set -gx PGPASSWORD 'line1\nline2';\n",
+		},
+		{
+			shell: "dotenv",
+			env:   env,
+			want: "" +
+				`PGHOST="/tmp/my socket"` + "\n" +
+				`PGPASSWORD="it's \$HOME \"quoted\" \\n"` + "\n" +
+				`PGUSER="postgres"` + "\n",
+		},
+		{
+			shell: "dotenv",
+			env:   map[string]string{"PGPASSWORD": "line1\nline2"},
+			want:  `PGPASSWORD="line1\nline2"` + "\n",
+		},
+		{
+			shell: "github",
+			env:   env,
+			want: "" +
+				"PGHOST=/tmp/my socket\n" +
+				`PGPASSWORD=it's $HOME "quoted" \n` + "\n" +
+				"PGUSER=postgres\n",
+		},
+		{
+			shell: "github",
+			env:   map[string]string{"PGPASSWORD": "line1\nline2"},
+			want:  "PGPASSWORD<<PGDEVSERVER_EOF\nline1\nline2\nPGDEVSERVER_EOF\n",
+		},
+		{
+			shell: "github",
+			env:   map[string]string{"PGPASSWORD": "line1\nPGDEVSERVER_EOF\nPGDEVSERVER_EOF_1"},
+			want:  "PGPASSWORD<<PGDEVSERVER_EOF_2\nline1\nPGDEVSERVER_EOF\nPGDEVSERVER_EOF_1\nPGDEVSERVER_EOF_2\n",
+		},
+	} {
+		t.Run(tc.shell, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, writeEnv(&buf, tc.shell, tc.env))
+			require.Equal(t, tc.want, buf.String())
+		})
+	}
+
+	t.Run("bash round trip", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("requires /bin/sh")
+		}
+		var buf bytes.Buffer
+		want := "it's $HOME\n\"quoted\" \\n `pwd`"
+		require.NoError(t, writeEnv(&buf, "bash", map[string]string{"PGPASSWORD": want}))
+		out, err := exec.Command("/bin/sh", "-c", buf.String()+`printf %s "$PGPASSWORD"`).Output()
+		require.NoError(t, err)
+		require.Equal(t, want, string(out))
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.EqualError(t, writeEnv(&buf, "cmd", env), `unsupported shell "cmd"`)
+	})
+}
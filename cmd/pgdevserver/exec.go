@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+type execCmd struct {
+	ServerParams serverParams `kong:"embed,group='Server Options'"`
+	CacheParams  cacheParams  `kong:"embed"`
+	Stop         bool         `kong:"help='Stop the server when the command exits.'"`
+	Rm           bool         `kong:"name='rm',help='Remove the server when the command exits.'"`
+	Command      []string     `kong:"arg,passthrough,help='Command to run.'"`
+}
+
+func (c *execCmd) Run() error {
+	// kong keeps the -- that separates the command from pgdevserver's flags
+	command := c.Command
+	if len(command) > 0 && command[0] == "--" {
+		command = command[1:]
+	}
+	if len(command) == 0 {
+		return errors.New("no command to run. Pass one after --")
+	}
+	ctx := context.Background()
+	srv, err := c.ServerParams.server(c.CacheParams)
+	if err != nil {
+		return err
+	}
+	err = srv.Start(ctx)
+	if err != nil {
+		return err
+	}
+	env, err := srv.Env(ctx)
+	if err != nil {
+		return err
+	}
+	code, err := c.run(ctx, srv, command, env)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		os.Exit(code)
+	}
+	return nil
+}
+
+// execServer is the part of *pgdevserver.Server that exec cleans up with.
+type execServer interface {
+	Stop(ctx context.Context) error
+	Remove(ctx context.Context) error
+}
+
+// run runs command and then stops or removes srv according to the flags. It returns the command's exit code.
+func (c *execCmd) run(ctx context.Context, srv execServer, command []string, env map[string]string) (int, error) {
+	cmdErr := runWithEnv(command, env)
+	var exitErr *exec.ExitError
+	if cmdErr != nil && !errors.As(cmdErr, &exitErr) {
+		return 0, errors.Join(cmdErr, c.cleanup(ctx, srv))
+	}
+	err := c.cleanup(ctx, srv)
+	if err != nil {
+		return 0, err
+	}
+	if exitErr != nil {
+		return exitCode(exitErr), nil
+	}
+	return 0, nil
+}
+
+func (c *execCmd) cleanup(ctx context.Context, srv execServer) error {
+	switch {
+	case c.Rm:
+		return srv.Remove(ctx)
+	case c.Stop:
+		return srv.Stop(ctx)
+	default:
+		return nil
+	}
+}
+
+// exitCode returns the exit code to exit with for a command that failed. Like shells, it uses 128 plus the signal
+// number for a command killed by a signal, which ExitCode reports as -1.
+func exitCode(exitErr *exec.ExitError) int {
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return exitErr.ExitCode()
+}
+
+// runWithEnv runs command with env added to the environment, forwarding signals to it until it exits.
+func runWithEnv(command []string, env map[string]string) error {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	defer signal.Stop(signals)
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				// an error means the process has already exited
+				if cmd.Process.Signal(sig) != nil {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return cmd.Wait()
+}
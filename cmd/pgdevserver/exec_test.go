@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeExecServer records how exec cleaned up.
+type fakeExecServer struct {
+	stopped, removed bool
+	err              error
+}
+
+func (s *fakeExecServer) Stop(context.Context) error {
+	s.stopped = true
+	return s.err
+}
+
+func (s *fakeExecServer) Remove(context.Context) error {
+	s.removed = true
+	return s.err
+}
+
+func Test_execCmd_run(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("commands are shell scripts")
+	}
+	ctx := context.Background()
+
+	t.Run("env", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "out")
+		t.Setenv("PGDEVSERVER_TEST_INHERITED", "inherited")
+		env := map[string]string{"PGHOST": "localhost", "PGPASSWORD": "it's a $secret"}
+		cmd := execCmd{}
+		code, err := cmd.run(ctx, &fakeExecServer{}, []string{
+			"sh", "-c", `printf '%s\n' "$PGHOST" "$PGPASSWORD" "$PGDEVSERVER_TEST_INHERITED" > "$1"`, "sh", out,
+		}, env)
+		require.NoError(t, err)
+		require.Equal(t, 0, code)
+		got, err := os.ReadFile(out)
+		require.NoError(t, err)
+		require.Equal(t, "localhost\nit's a $secret\ninherited\n", string(got))
+	})
+
+	t.Run("exit code", func(t *testing.T) {
+		cmd := execCmd{}
+		code, err := cmd.run(ctx, &fakeExecServer{}, []string{"sh", "-c", "exit 3"}, nil)
+		require.NoError(t, err)
+		require.Equal(t, 3, code)
+	})
+
+	t.Run("signal", func(t *testing.T) {
+		cmd := execCmd{}
+		code, err := cmd.run(ctx, &fakeExecServer{}, []string{"sh", "-c", "kill -TERM $$"}, nil)
+		require.NoError(t, err)
+		require.Equal(t, 128+15, code)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		srv := &fakeExecServer{}
+		cmd := execCmd{Stop: true}
+		code, err := cmd.run(ctx, srv, []string{"sh", "-c", "exit 1"}, nil)
+		require.NoError(t, err)
+		require.Equal(t, 1, code)
+		require.True(t, srv.stopped)
+		require.False(t, srv.removed)
+	})
+
+	t.Run("rm", func(t *testing.T) {
+		srv := &fakeExecServer{}
+		cmd := execCmd{Stop: true, Rm: true}
+		code, err := cmd.run(ctx, srv, []string{"true"}, nil)
+		require.NoError(t, err)
+		require.Equal(t, 0, code)
+		require.True(t, srv.removed)
+		require.False(t, srv.stopped)
+	})
+
+	t.Run("no cleanup", func(t *testing.T) {
+		srv := &fakeExecServer{}
+		cmd := execCmd{}
+		_, err := cmd.run(ctx, srv, []string{"true"}, nil)
+		require.NoError(t, err)
+		require.False(t, srv.stopped)
+		require.False(t, srv.removed)
+	})
+
+	t.Run("cleanup error", func(t *testing.T) {
+		srv := &fakeExecServer{err: errors.New("boom")}
+		cmd := execCmd{Rm: true}
+		_, err := cmd.run(ctx, srv, []string{"sh", "-c", "exit 1"}, nil)
+		require.EqualError(t, err, "boom")
+	})
+
+	t.Run("command not found", func(t *testing.T) {
+		srv := &fakeExecServer{}
+		cmd := execCmd{Stop: true}
+		_, err := cmd.run(ctx, srv, []string{filepath.Join(t.TempDir(), "missing")}, nil)
+		require.ErrorIs(t, err, os.ErrNotExist)
+		require.True(t, srv.stopped)
+	})
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+type formatParams struct {
+	Format string `kong:"default='table',enum='table,json,yaml',help='Output format. One of table, json or yaml.'"`
+}
+
+// writeFormatted writes v to w as json or yaml. Table output is specific to each command, so it is left to the
+// caller.
+func writeFormatted(w io.Writer, format string, v any) (errOut error) {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		defer func() { errOut = errors.Join(errOut, enc.Close()) }()
+		enc.SetIndent(2)
+		return enc.Encode(v)
+	default:
+		return fmt.Errorf("unsupported format %q", format)
+	}
+}
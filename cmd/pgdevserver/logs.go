@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// logLevels are postgres message severities from least to most severe. LOG is placed between NOTICE and WARNING
+// so that filtering by WARNING or ERROR hides routine server messages.
+var logLevels = []string{"DEBUG", "INFO", "NOTICE", "LOG", "WARNING", "ERROR", "FATAL", "PANIC"}
+
+// logLinePattern matches lines written with the default log_line_prefix of '%m [%p] '.
+var logLinePattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)? \S+) \[\d+\] ([A-Z]+[0-9]?):`)
+
+const logTimeLayout = "2006-01-02 15:04:05.999 MST"
+
+type logsCmd struct {
+	ServerParams serverParams  `kong:"embed,group='Server Options'"`
+	CacheParams  cacheParams   `kong:"embed"`
+	Follow       bool          `kong:"short='f',help='Keep printing new log lines as they are written.'"`
+	Since        time.Duration `kong:"help='Only show entries newer than this duration.'"`
+	Level        string        `kong:"help='Only show entries at this severity or higher. One of DEBUG, INFO, NOTICE, LOG, WARNING, ERROR, FATAL or PANIC.'"`
+}
+
+func (c *logsCmd) Run() (errOut error) {
+	ctx := context.Background()
+	// lines before the first recognized entry are only shown when nothing is filtered
+	filter := logFilter{include: c.Level == "" && c.Since == 0}
+	if c.Level != "" {
+		filter.minLevel = slices.Index(logLevels, strings.ToUpper(c.Level))
+		if filter.minLevel == -1 {
+			return fmt.Errorf("unknown level %q", c.Level)
+		}
+	}
+	if c.Since > 0 {
+		filter.since = time.Now().Add(-c.Since)
+	}
+	srv, err := c.ServerParams.server(c.CacheParams)
+	if err != nil {
+		return err
+	}
+	if c.Since > 0 {
+		var dataDir string
+		dataDir, err = srv.DataDir(ctx)
+		if err != nil {
+			return err
+		}
+		filter.location, err = logLocation(dataDir, srv.Config().PostgresOptions)
+		if err != nil {
+			return err
+		}
+	}
+	logfile, err := srv.Logfile(ctx)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(logfile)
+	if errors.Is(err, os.ErrNotExist) && !c.Follow {
+		return nil
+	}
+	for errors.Is(err, os.ErrNotExist) {
+		time.Sleep(time.Second)
+		f, err = os.Open(logfile)
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { errOut = errors.Join(errOut, f.Close()) }()
+	return tailLog(f, os.Stdout, &filter, c.Follow)
+}
+
+// tailLog copies the filtered lines of f to w. When follow is set, it polls for new lines until interrupted and
+// starts over if the file is truncated.
+func tailLog(f *os.File, w io.Writer, filter *logFilter, follow bool) error {
+	reader := bufio.NewReader(f)
+	var partial string
+	for {
+		chunk, err := reader.ReadString('\n')
+		partial += chunk
+		if err == nil {
+			if filter.keep(strings.TrimSuffix(partial, "\n")) {
+				_, err = io.WriteString(w, partial)
+				if err != nil {
+					return err
+				}
+			}
+			partial = ""
+			continue
+		}
+		if !errors.Is(err, io.EOF) {
+			return err
+		}
+		if !follow {
+			if partial == "" || !filter.keep(partial) {
+				return nil
+			}
+			_, err = fmt.Fprintln(w, partial)
+			return err
+		}
+		time.Sleep(250 * time.Millisecond)
+		truncated, err := fileTruncated(f)
+		if err != nil {
+			return err
+		}
+		if truncated {
+			_, err = f.Seek(0, io.SeekStart)
+			if err != nil {
+				return err
+			}
+			reader.Reset(f)
+			partial = ""
+		}
+	}
+}
+
+// fileTruncated reports whether f is now shorter than the current read offset.
+func fileTruncated(f *os.File) (bool, error) {
+	offset, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return false, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	return info.Size() < offset, nil
+}
+
+// logFilter decides which log lines to show. Lines without a recognized prefix, such as DETAIL lines and
+// continuations of multi-line messages, follow the decision for the entry they belong to.
+type logFilter struct {
+	minLevel int
+	since    time.Time
+	// location is the server's log_timezone. Timestamps only include a zone abbreviation, which is ambiguous
+	// without it. Default is the local time zone.
+	location *time.Location
+	include  bool
+}
+
+func (f *logFilter) keep(line string) bool {
+	m := logLinePattern.FindStringSubmatch(line)
+	if m == nil {
+		return f.include
+	}
+	level := logLevelIndex(m[2])
+	// qualifiers like DETAIL and HINT belong to the previous entry
+	if level == -1 {
+		return f.include
+	}
+	f.include = level >= f.minLevel
+	if !f.since.IsZero() {
+		ts, err := time.ParseInLocation(logTimeLayout, m[1], cmp.Or(f.location, time.Local))
+		if err == nil && ts.Before(f.since) {
+			f.include = false
+		}
+	}
+	return f.include
+}
+
+// logLevelIndex returns the position of severity in logLevels or -1 if it is not a message severity.
+func logLevelIndex(severity string) int {
+	if strings.HasPrefix(severity, "DEBUG") {
+		return 0
+	}
+	return slices.Index(logLevels, severity)
+}
+
+// Patterns for log_timezone settings in config files and in postgres options. The value is the first submatch.
+var (
+	logTimezoneConfPattern   = regexp.MustCompile(`(?m)^\s*log_timezone\s*=?\s*'?([^'#\s]+)`)
+	logTimezoneOptionPattern = regexp.MustCompile(`log_timezone\s*=\s*'?([^'\s]+)`)
+)
+
+// logLocation returns the time zone the server writes log timestamps in. Like postgres, it uses the last setting in
+// postgresql.conf, then postgresql.auto.conf, then the server's options. Servers without a setting use the local
+// time zone, which is what initdb writes to postgresql.conf.
+func logLocation(dataDir string, options []string) (*time.Location, error) {
+	var name string
+	for _, filename := range []string{"postgresql.conf", "postgresql.auto.conf"} {
+		b, err := os.ReadFile(filepath.Join(dataDir, filename))
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			continue
+		case err != nil:
+			return nil, err
+		}
+		for _, m := range logTimezoneConfPattern.FindAllStringSubmatch(string(b), -1) {
+			name = m[1]
+		}
+	}
+	for _, option := range options {
+		for _, m := range logTimezoneOptionPattern.FindAllStringSubmatch(option, -1) {
+			name = m[1]
+		}
+	}
+	if name == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("loading log_timezone: %w", err)
+	}
+	return loc, nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_logLinePattern(t *testing.T) {
+	for _, tc := range []struct {
+		line  string
+		want  []string
+		match bool
+	}{
+		{
+			line:  "2024-06-01 12:00:00.123 CEST [123] LOG:  database system is ready",
+			want:  []string{"2024-06-01 12:00:00.123 CEST", "LOG"},
+			match: true,
+		},
+		{
+			line:  "2024-06-01 12:00:00 UTC [1] DEBUG2:  checkpoint",
+			want:  []string{"2024-06-01 12:00:00 UTC", "DEBUG2"},
+			match: true,
+		},
+		{
+			line:  "2024-06-01 12:00:00.123 +03 [42] DETAIL:  key exists",
+			want:  []string{"2024-06-01 12:00:00.123 +03", "DETAIL"},
+			match: true,
+		},
+		{line: "\tcontinued message"},
+		{line: "waiting for server to start.... done"},
+	} {
+		m := logLinePattern.FindStringSubmatch(tc.line)
+		if !tc.match {
+			require.Nil(t, m, tc.line)
+			continue
+		}
+		require.Equal(t, tc.want, m[1:], tc.line)
+	}
+}
+
+func Test_logFilter_keep(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	t.Run("level", func(t *testing.T) {
+		filter := logFilter{minLevel: logLevelIndex("WARNING")}
+		require.False(t, filter.keep("startup output"))
+		require.False(t, filter.keep("2024-06-01 12:00:00.000 UTC [1] LOG:  ready"))
+		require.False(t, filter.keep("2024-06-01 12:00:00.000 UTC [1] DETAIL:  more"))
+		require.True(t, filter.keep("2024-06-01 12:00:01.000 UTC [1] ERROR:  broken"))
+		require.True(t, filter.keep("2024-06-01 12:00:01.000 UTC [1] STATEMENT:  SELECT 1"))
+		require.True(t, filter.keep("\tcontinued"))
+		require.False(t, filter.keep("2024-06-01 12:00:02.000 UTC [1] DEBUG1:  noise"))
+	})
+
+	t.Run("since", func(t *testing.T) {
+		filter := logFilter{
+			since:    time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC),
+			location: berlin,
+		}
+		// 11:59 CEST is 09:59 UTC
+		require.False(t, filter.keep("2024-06-01 11:59:59.999 CEST [1] ERROR:  old"))
+		require.False(t, filter.keep("\tcontinued"))
+		require.True(t, filter.keep("2024-06-01 12:00:00.000 CEST [1] LOG:  new"))
+		require.True(t, filter.keep("2024-06-01 12:00:00.000 CEST [1] HINT:  more"))
+	})
+
+	t.Run("since in winter", func(t *testing.T) {
+		filter := logFilter{
+			since:    time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+			location: berlin,
+		}
+		// 10:59 CET is 09:59 UTC
+		require.False(t, filter.keep("2024-01-01 10:59:00.000 CET [1] LOG:  old"))
+		require.True(t, filter.keep("2024-01-01 11:00:00.000 CET [1] LOG:  new"))
+	})
+}
+
+func Test_tailLog(t *testing.T) {
+	logfile := filepath.Join(t.TempDir(), "server.log")
+	content := strings.Join([]string{
+		"waiting for server to start",
+		"2024-06-01 12:00:00.000 UTC [1] LOG:  ready",
+		"2024-06-01 12:00:01.000 UTC [1] ERROR:  broken",
+		"2024-06-01 12:00:01.000 UTC [1] DETAIL:  details",
+		"2024-06-01 12:00:02.000 UTC [1] LOG:  no newline",
+	}, "\n")
+	require.NoError(t, os.WriteFile(logfile, []byte(content), 0o600))
+
+	tail := func(t *testing.T, filter logFilter) string {
+		t.Helper()
+		f, err := os.Open(logfile)
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, f.Close()) })
+		var buf bytes.Buffer
+		require.NoError(t, tailLog(f, &buf, &filter, false))
+		return buf.String()
+	}
+
+	t.Run("unfiltered", func(t *testing.T) {
+		require.Equal(t, content+"\n", tail(t, logFilter{include: true}))
+	})
+
+	t.Run("level", func(t *testing.T) {
+		got := tail(t, logFilter{minLevel: logLevelIndex("ERROR")})
+		require.Equal(t, ""+
+			"2024-06-01 12:00:01.000 UTC [1] ERROR:  broken\n"+
+			"2024-06-01 12:00:01.000 UTC [1] DETAIL:  details\n", got)
+	})
+
+	t.Run("since", func(t *testing.T) {
+		got := tail(t, logFilter{
+			since:    time.Date(2024, 6, 1, 12, 0, 2, 0, time.UTC),
+			location: time.UTC,
+		})
+		require.Equal(t, "2024-06-01 12:00:02.000 UTC [1] LOG:  no newline\n", got)
+	})
+}
+
+func Test_logLocation(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		conf    string
+		auto    string
+		options []string
+		want    string
+		wantErr bool
+	}{
+		{name: "no setting", want: "Local"},
+		{
+			name: "postgresql.conf",
+			conf: "#log_timezone = 'GMT'\nlog_timezone = 'Europe/Berlin'\t# set by initdb\n",
+			want: "Europe/Berlin",
+		},
+		{
+			name: "auto conf overrides",
+			conf: "log_timezone = 'Europe/Berlin'\n",
+			auto: "log_timezone = 'America/Chicago'\n",
+			want: "America/Chicago",
+		},
+		{
+			name:    "options override",
+			conf:    "log_timezone = 'Europe/Berlin'\n",
+			options: []string{"-c 'fsync=off' -c log_timezone=Asia/Tokyo"},
+			want:    "Asia/Tokyo",
+		},
+		{
+			name:    "quoted option",
+			options: []string{"-c 'log_timezone=UTC'"},
+			want:    "UTC",
+		},
+		{
+			name:    "unknown zone",
+			conf:    "log_timezone = 'Nowhere/Special'\n",
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			if tc.conf != "" {
+				require.NoError(t, os.WriteFile(filepath.Join(dataDir, "postgresql.conf"), []byte(tc.conf), 0o600))
+			}
+			if tc.auto != "" {
+				require.NoError(t, os.WriteFile(filepath.Join(dataDir, "postgresql.auto.conf"), []byte(tc.auto), 0o600))
+			}
+			loc, err := logLocation(dataDir, tc.options)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, loc.String())
+		})
+	}
+}
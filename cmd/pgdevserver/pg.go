@@ -2,10 +2,9 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"path/filepath"
-
-	"github.com/willabides/pgdevserver"
+	"os"
 )
 
 type pgCmd struct {
@@ -13,20 +12,26 @@ type pgCmd struct {
 	Available pgAvailableCmd `kong:"cmd,help='List postgres versions available to download.'"`
 	Install   pgInstallCmd   `kong:"cmd,help='Install a postgres version.'"`
 	Rm        pgRmCmd        `kong:"cmd,help='Remove a postgres version.'"`
+	Export    pgExportCmd    `kong:"cmd,help='Write an installed postgres version to a bundle for pg import.'"`
+	Import    pgImportCmd    `kong:"cmd,help='Install postgres from a bundle written by pg export.'"`
 }
 
 type pgListCmd struct {
-	CacheParams cacheParams `kong:"embed"`
+	CacheParams  cacheParams  `kong:"embed"`
+	FormatParams formatParams `kong:"embed"`
 }
 
 func (c *pgListCmd) Run() (errOut error) {
-	mgr := pgdevserver.NewPGManager(pgdevserver.PGMConfig{
-		CacheDir: filepath.Join(c.CacheParams.cacheDir(), "postgres"),
-	})
+	mgr := c.CacheParams.pgManager()
 	versions, err := mgr.InstalledVersions()
 	if err != nil {
 		return err
 	}
+	if c.FormatParams.Format != "table" {
+		// encode an empty list rather than null
+		versions = append([]string{}, versions...)
+		return writeFormatted(os.Stdout, c.FormatParams.Format, versions)
+	}
 	for _, version := range versions {
 		fmt.Println(version)
 	}
@@ -34,18 +39,22 @@ func (c *pgListCmd) Run() (errOut error) {
 }
 
 type pgAvailableCmd struct {
-	CacheParams cacheParams `kong:"embed"`
+	CacheParams  cacheParams  `kong:"embed"`
+	FormatParams formatParams `kong:"embed"`
 }
 
 func (c *pgAvailableCmd) Run() error {
 	ctx := context.Background()
-	mgr := pgdevserver.NewPGManager(pgdevserver.PGMConfig{
-		CacheDir: filepath.Join(c.CacheParams.cacheDir(), "postgres"),
-	})
+	mgr := c.CacheParams.pgManager()
 	versions, err := mgr.AvailableVersions(ctx)
 	if err != nil {
 		return err
 	}
+	if c.FormatParams.Format != "table" {
+		// encode an empty list rather than null
+		versions = append([]string{}, versions...)
+		return writeFormatted(os.Stdout, c.FormatParams.Format, versions)
+	}
 	for _, version := range versions {
 		fmt.Println(version)
 	}
@@ -54,14 +63,22 @@ func (c *pgAvailableCmd) Run() error {
 
 type pgInstallCmd struct {
 	CacheParams cacheParams `kong:"embed"`
-	Version     string      `kong:"arg,help='The version to install.'"`
+	Version     string      `kong:"arg,help='The version to install. Also accepts constraints like 17, ~16.4 or latest.'"`
 }
 
 func (c *pgInstallCmd) Run() error {
-	mgr := pgdevserver.NewPGManager(pgdevserver.PGMConfig{
-		CacheDir: filepath.Join(c.CacheParams.cacheDir(), "postgres"),
-	})
-	return mgr.Install(context.Background(), c.Version)
+	ctx := context.Background()
+	mgr := c.CacheParams.pgManager()
+	version, err := mgr.ResolveVersion(ctx, c.Version)
+	if err != nil {
+		return err
+	}
+	err = mgr.Install(ctx, version)
+	if err != nil {
+		return err
+	}
+	fmt.Println(version)
+	return nil
 }
 
 type pgRmCmd struct {
@@ -70,8 +87,54 @@ type pgRmCmd struct {
 }
 
 func (c *pgRmCmd) Run() error {
-	mgr := pgdevserver.NewPGManager(pgdevserver.PGMConfig{
-		CacheDir: filepath.Join(c.CacheParams.cacheDir(), "postgres"),
-	})
+	mgr := c.CacheParams.pgManager()
 	return mgr.Remove(c.Version)
 }
+
+type pgExportCmd struct {
+	CacheParams cacheParams `kong:"embed"`
+	Version     string      `kong:"arg,help='The version to export.'"`
+	Output      string      `kong:"short='o',required,help='File to write the bundle to. Use - for stdout.',placeholder='bundle.tar.zst'"`
+}
+
+func (c *pgExportCmd) Run() (errOut error) {
+	mgr := c.CacheParams.pgManager()
+	if c.Output == "-" {
+		return mgr.Export(context.Background(), c.Version, os.Stdout)
+	}
+	f, err := os.Create(c.Output)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		errOut = errors.Join(errOut, f.Close())
+		if errOut != nil {
+			errOut = errors.Join(errOut, os.Remove(c.Output))
+		}
+	}()
+	return mgr.Export(context.Background(), c.Version, f)
+}
+
+type pgImportCmd struct {
+	CacheParams cacheParams `kong:"embed"`
+	Bundle      string      `kong:"arg,type='existingfile',help='Bundle file to import. Use - for stdin.'"`
+}
+
+func (c *pgImportCmd) Run() (errOut error) {
+	mgr := c.CacheParams.pgManager()
+	in := os.Stdin
+	if c.Bundle != "-" {
+		f, err := os.Open(c.Bundle)
+		if err != nil {
+			return err
+		}
+		defer func() { errOut = errors.Join(errOut, f.Close()) }()
+		in = f
+	}
+	version, err := mgr.Import(context.Background(), in)
+	if err != nil {
+		return err
+	}
+	fmt.Println(version)
+	return nil
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"cmp"
+	"fmt"
+	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/adrg/xdg"
 	"github.com/alecthomas/kong"
@@ -18,56 +21,151 @@ const (
 )
 
 var help = kong.Vars{
-	"serverNameHelp": "A name to distinguish this server from others that have the same configuration.",
-	"cacheHelp":      "Cache for binaries and server data. Defaults to $XDG_CACHE_HOME/pgdevserver.",
-	"initDBArgsHelp": "Extra arguments to pass to initdb. May be specified multiple times.",
-	"postgresHelp":   "Postgres version.",
-	"portHelp":       "Port to listen on. When left empty, a random port will be chosen.",
-	"optionHelp":     "Extra options to pass to postgres. May be specified multiple times.",
+	"serverNameHelp":  "A name to distinguish this server from others that have the same configuration.",
+	"cacheHelp":       "Cache for binaries and server data. Defaults to $XDG_CACHE_HOME/pgdevserver.",
+	"initDBArgsHelp":  "Extra arguments to pass to initdb. May be specified multiple times.",
+	"postgresHelp":    "Postgres version. Also accepts constraints like 17, ~16.4 or '>=15 <17' and latest, which prefer the version of an existing server with the same options, then installed versions.",
+	"portHelp":        "Port to listen on. When left empty, a random port will be chosen.",
+	"optionHelp":      "Extra options to pass to postgres. May be specified multiple times.",
+	"socketDirHelp":   "Directory for the unix domain socket. Defaults to the server's cache directory when --no-tcp is set.",
+	"noTCPHelp":       "Only listen on a unix domain socket.",
+	"userHelp":        "Name of the database superuser. Defaults to postgres.",
+	"passwordHelp":    "Password for the database superuser. Required for md5 and scram-sha-256 auth.",
+	"authMethodHelp":  "Authentication method for connections. One of trust, md5 or scram-sha-256. Defaults to trust.",
+	"idleHelp":        "Stop the server after it has had no client connections for this long. Zero disables.",
+	"ttlHelp":         "Remove the server with gc once it is this old. Zero means never.",
+	"initScriptsHelp": "Directory of .sql, .sql.gz and .sh scripts to run the first time a new server starts.",
+	"tlsHelp":         "Enable TLS with a certificate signed by a generated CA.",
+	"systemPGHelp":    "Use postgres installed on the system: never, prefer (download versions that aren't installed) or only (never download). Also searches the directories in PGDEVSERVER_PG_BIN.",
+	"mavenURLHelp":    "Maven repository to download postgres from. May be specified multiple times to fall back to mirrors in order.",
 }
 
 type serverParams struct {
-	ID              string   `kong:"help='Act on the server with this ID. When set, other server options are ignored.'"`
-	PostgresVersion string   `kong:"name='pg',default='17.2.0',help=${postgresHelp}"`
-	ServerName      string   `kong:"default='default',help=${serverNameHelp}"`
-	InitDBArgs      []string `kong:"help=${initDBArgsHelp},placeholder='arg'"`
-	Port            string   `kong:"help=${portHelp}"`
-	PGOptions       []string `kong:"name='option',short='o',help='Extra options to pass to postgres. May be specified multiple times.',placeholder='option'"`
-	Recommended     bool     `kong:"name='recommended',help='Use recommended options'"`
+	ID              string        `kong:"help='Act on the server with this ID. When set, other server options are ignored.'"`
+	PostgresVersion string        `kong:"name='pg',default='latest',env='PGDEVSERVER_PG',help=${postgresHelp}"`
+	ServerName      string        `kong:"default='default',env='PGDEVSERVER_SERVER_NAME',help=${serverNameHelp}"`
+	InitDBArgs      []string      `kong:"env='PGDEVSERVER_INIT_DB_ARGS',help=${initDBArgsHelp},placeholder='arg'"`
+	Port            string        `kong:"env='PGDEVSERVER_PORT',help=${portHelp}"`
+	PGOptions       []string      `kong:"name='option',short='o',env='PGDEVSERVER_OPTION',help='Extra options to pass to postgres. May be specified multiple times.',placeholder='option'"`
+	Recommended     bool          `kong:"name='recommended',env='PGDEVSERVER_RECOMMENDED',help='Use recommended options'"`
+	SocketDir       string        `kong:"env='PGDEVSERVER_SOCKET_DIR',help=${socketDirHelp},type='path'"`
+	NoTCP           bool          `kong:"name='no-tcp',env='PGDEVSERVER_NO_TCP',help=${noTCPHelp}"`
+	User            string        `kong:"env='PGDEVSERVER_USER',help=${userHelp}"`
+	Password        string        `kong:"env='PGDEVSERVER_PASSWORD',help=${passwordHelp}"`
+	AuthMethod      string        `kong:"env='PGDEVSERVER_AUTH_METHOD',help=${authMethodHelp}"`
+	TLS             bool          `kong:"name='tls',env='PGDEVSERVER_TLS',help=${tlsHelp}"`
+	IdleTimeout     time.Duration `kong:"env='PGDEVSERVER_IDLE_TIMEOUT',help=${idleHelp}"`
+	TTL             time.Duration `kong:"name='ttl',env='PGDEVSERVER_TTL',help=${ttlHelp}"`
+	InitScripts     string        `kong:"type='existingdir',env='PGDEVSERVER_INIT_SCRIPTS',help=${initScriptsHelp}"`
 }
 
-func (p *serverParams) server(rootCache string) (*pgdevserver.Server, error) {
+func (p *serverParams) server(cache cacheParams) (*pgdevserver.Server, error) {
+	rootCache := cache.cacheDir()
 	if p.ID != "" {
-		return pgdevserver.ServerFromCache(rootCache, p.ID)
+		srv, err := pgdevserver.ServerFromCache(rootCache, p.ID)
+		if err != nil {
+			return nil, err
+		}
+		return cache.withPGManager(srv), nil
 	}
 	pgOptions := p.PGOptions
 	if p.Recommended {
 		pgOptions = append([]string{recommendedOptions}, pgOptions...)
 	}
-	return pgdevserver.New(pgdevserver.Config{
+	srv := pgdevserver.New(pgdevserver.Config{
 		PostgresVersion: p.PostgresVersion,
 		CacheDir:        rootCache,
 		Name:            p.ServerName,
 		InitDBArgs:      p.InitDBArgs,
 		Port:            p.Port,
 		PostgresOptions: pgOptions,
-	}), nil
+		SocketDir:       p.SocketDir,
+		DisableTCP:      p.NoTCP,
+		User:            p.User,
+		Password:        p.Password,
+		AuthMethod:      p.AuthMethod,
+		TLS:             p.TLS,
+		IdleTimeout:     p.IdleTimeout,
+		TTL:             p.TTL,
+		InitScriptsDir:  p.InitScripts,
+		PGManager:       cache.pgManager(),
+	})
+	err := srv.Err()
+	if err != nil {
+		return nil, err
+	}
+	return srv, nil
 }
 
 type rootCmd struct {
-	ServerCmds serverCmds `kong:"embed"`
-	Pg         pgCmd      `kong:"cmd,help='Manage postgres binaries'"`
+	ServerCmds serverCmds  `kong:"embed"`
+	Snapshot   snapshotCmd `kong:"cmd,help='Manage server snapshots'"`
+	Pg         pgCmd       `kong:"cmd,help='Manage postgres binaries'"`
 }
 
 type cacheParams struct {
-	Cache string `kong:"help=${cacheHelp}"`
+	Cache    string   `kong:"type='path',env='PGDEVSERVER_CACHE',help=${cacheHelp}"`
+	MavenURL []string `kong:"name='maven-url',help=${mavenURLHelp},placeholder='url'"`
+	SystemPG string   `kong:"name='system-pg',default='never',enum='never,prefer,only',help=${systemPGHelp}"`
 }
 
 func (p cacheParams) cacheDir() string {
 	return cmp.Or(p.Cache, filepath.Join(xdg.CacheHome, "pgdevserver"))
 }
 
+func (p cacheParams) pgManager() *pgdevserver.PGManager {
+	return pgdevserver.NewPGManager(pgdevserver.PGMConfig{
+		CacheDir:       filepath.Join(p.cacheDir(), "postgres"),
+		MavenURLs:      p.MavenURL,
+		Progress:       terminalProgress(os.Stderr),
+		SystemPostgres: p.systemPostgres(),
+	})
+}
+
+func (p cacheParams) systemPostgres() pgdevserver.SystemPostgresMode {
+	switch p.SystemPG {
+	case "prefer":
+		return pgdevserver.SystemPostgresPrefer
+	case "only":
+		return pgdevserver.SystemPostgresOnly
+	default:
+		return pgdevserver.SystemPostgresNever
+	}
+}
+
+// withPGManager returns a copy of srv that installs postgres with p.pgManager. Servers loaded from the cache
+// otherwise use a default PGManager that ignores these flags.
+func (p cacheParams) withPGManager(srv *pgdevserver.Server) *pgdevserver.Server {
+	cfg := srv.Config()
+	cfg.PGManager = p.pgManager()
+	return pgdevserver.New(cfg)
+}
+
 func main() {
-	cli := kong.Parse(&rootCmd{}, help)
+	pgdevserver.RunIdleWatcher()
+	options := []kong.Option{help}
+	resolver, err := discoverProjectConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pgdevserver: loading project config: %v\n", err)
+		os.Exit(1)
+	}
+	if resolver != nil {
+		options = append(options, kong.Resolvers(resolver))
+	}
+	cli := kong.Parse(&rootCmd{}, options...)
 	cli.FatalIfErrorf(cli.Run())
 }
+
+// discoverProjectConfig returns a resolver for the project config file nearest to the working directory or nil
+// if there is none.
+func discoverProjectConfig() (kong.Resolver, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	filename, err := findProjectConfig(wd)
+	if err != nil || filename == "" {
+		return nil, err
+	}
+	return projectConfigResolver(filename)
+}
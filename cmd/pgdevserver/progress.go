@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/willabides/pgdevserver"
+)
+
+// progressBar renders postgres install progress on a terminal.
+type progressBar struct {
+	w        io.Writer
+	phase    pgdevserver.ProgressPhase
+	complete bool
+	lastDraw time.Time
+}
+
+// terminalProgress returns a callback that draws a progress bar on f or nil if f is not a terminal.
+func terminalProgress(f *os.File) func(pgdevserver.Progress) {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	bar := progressBar{w: f}
+	return bar.update
+}
+
+func (b *progressBar) update(p pgdevserver.Progress) {
+	if p.Phase != b.phase {
+		if b.phase != "" && !b.complete {
+			fmt.Fprintln(b.w)
+		}
+		b.phase = p.Phase
+		b.complete = false
+		b.lastDraw = time.Time{}
+	}
+	if b.complete {
+		return
+	}
+	b.complete = p.Total >= 0 && p.Done >= p.Total
+	if !b.complete && time.Since(b.lastDraw) < 100*time.Millisecond {
+		return
+	}
+	b.lastDraw = time.Now()
+	line := fmt.Sprintf("%-8s postgres %s", p.Phase, p.Version)
+	if p.Total > 0 {
+		const width = 30
+		done := min(p.Done, p.Total)
+		filled := int(done * width / p.Total)
+		line += fmt.Sprintf(" [%s%s] %3d%% %s/%s",
+			strings.Repeat("=", filled), strings.Repeat(" ", width-filled),
+			done*100/p.Total, formatBytes(done), formatBytes(p.Total),
+		)
+	} else {
+		line += " " + formatBytes(p.Done)
+	}
+	fmt.Fprint(b.w, "\r"+line)
+	if b.complete {
+		fmt.Fprintln(b.w)
+	}
+}
+
+func formatBytes(n int64) string {
+	const mb = 1 << 20
+	return fmt.Sprintf("%.1f MB", float64(n)/mb)
+}
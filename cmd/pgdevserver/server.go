@@ -6,29 +6,39 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
 	"sync"
 	"text/tabwriter"
+	"time"
 
 	"github.com/willabides/pgdevserver"
-	"github.com/willabides/pgdevserver/internal/bdcache"
 )
 
 type serverCmds struct {
-	Start  startCmd    `kong:"cmd,help='Start a server.'"`
-	Create createCmd   `kong:"cmd,help='Create a server without starting it.'"`
-	List   listCmd     `kong:"cmd,help='List servers.'"`
-	Stop   stopCmd     `kong:"cmd,help='Stop a server.'"`
-	Rm     rmServerCmd `kong:"cmd,help='Remove a server.'"`
+	Start   startCmd    `kong:"cmd,help='Start a server.'"`
+	Create  createCmd   `kong:"cmd,help='Create a server without starting it.'"`
+	List    listCmd     `kong:"cmd,help='List servers.'"`
+	Stop    stopCmd     `kong:"cmd,help='Stop a server.'"`
+	Restart restartCmd  `kong:"cmd,help='Restart a server.'"`
+	Reload  reloadCmd   `kong:"cmd,help='Reload the configuration of a running server.'"`
+	Config  configCmd   `kong:"cmd,help='Manage postgres configuration parameters without changing the server ID.'"`
+	Rm      rmServerCmd `kong:"cmd,help='Remove a server.'"`
+	GC      gcCmd       `kong:"cmd,help='Remove expired and unused servers.'"`
+	Upgrade upgradeCmd  `kong:"cmd,help='Upgrade a server to a new postgres version.'"`
+	Exec    execCmd     `kong:"cmd,help='Run a command with connection environment variables for a server.'"`
+	Env     envCmd      `kong:"cmd,help='Print connection environment variables for a server.'"`
+	SQL     sqlCmd      `kong:"cmd,name='sql',help='Run SQL against a server.'"`
+	Shell   shellCmd    `kong:"cmd,help='Start an interactive SQL shell.'"`
+	Logs    logsCmd     `kong:"cmd,help='Show a server log.'"`
 }
 
 type listCmd struct {
-	CacheParams cacheParams `kong:"embed"`
-	Status      bool        `kong:"help='Show server status.'"`
-	URL         bool        `kong:"help='Show server connection URL for started servers.'"`
-	PG          bool        `kong:"help='Show postgres version.'"`
-	NoHeaders   bool        `kong:"help='Do not show headers.'"`
+	CacheParams  cacheParams  `kong:"embed"`
+	FormatParams formatParams `kong:"embed"`
+	Status       bool         `kong:"help='Show server status. Table format only.'"`
+	URL          bool         `kong:"help='Show server connection URL for started servers. Table format only.'"`
+	PG           bool         `kong:"help='Show postgres version. Table format only.'"`
+	NoHeaders    bool         `kong:"help='Do not show headers. Table format only.'"`
 }
 
 func (c *listCmd) Run() (errOut error) {
@@ -38,6 +48,19 @@ func (c *listCmd) Run() (errOut error) {
 		return err
 	}
 
+	if c.FormatParams.Format != "table" {
+		infos := make([]serverInfo, 0, len(servers))
+		for _, server := range servers {
+			var info serverInfo
+			info, err = newServerInfo(ctx, c.CacheParams.withPGManager(server))
+			if err != nil {
+				return err
+			}
+			infos = append(infos, info)
+		}
+		return writeFormatted(os.Stdout, c.FormatParams.Format, infos)
+	}
+
 	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
 	defer func() { errOut = errors.Join(errOut, tw.Flush()) }()
 
@@ -60,7 +83,7 @@ func (c *listCmd) Run() (errOut error) {
 	}
 
 	for _, server := range servers {
-		err = c.listServer(ctx, server, tw)
+		err = c.listServer(ctx, c.CacheParams.withPGManager(server), tw)
 		if err != nil {
 			return err
 		}
@@ -101,14 +124,64 @@ func (c *listCmd) listServer(ctx context.Context, server *pgdevserver.Server, tw
 	return err
 }
 
+// serverInfo describes a server for json and yaml output.
+type serverInfo struct {
+	ID              string   `json:"id" yaml:"id"`
+	Name            string   `json:"name" yaml:"name"`
+	PostgresVersion string   `json:"postgres_version" yaml:"postgres_version"`
+	Status          string   `json:"status" yaml:"status"`
+	Port            string   `json:"port" yaml:"port"`
+	URL             string   `json:"url,omitempty" yaml:"url,omitempty"`
+	DataDir         string   `json:"data_dir" yaml:"data_dir"`
+	PID             int      `json:"pid,omitempty" yaml:"pid,omitempty"`
+	Options         []string `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
+// newServerInfo gathers a serverInfo for server. The URL and PID are only set for running servers.
+func newServerInfo(ctx context.Context, server *pgdevserver.Server) (serverInfo, error) {
+	cfg := server.Config()
+	info := serverInfo{
+		ID:              server.ID(),
+		Name:            cfg.Name,
+		PostgresVersion: cfg.PostgresVersion,
+		Options:         cfg.PostgresOptions,
+	}
+	status, err := server.Status(ctx)
+	if err != nil {
+		status = pgdevserver.StatusUnknown
+	}
+	info.Status = status.String()
+	info.Port, err = server.Port(ctx)
+	if err != nil {
+		return serverInfo{}, err
+	}
+	info.DataDir, err = server.DataDir(ctx)
+	if err != nil {
+		return serverInfo{}, err
+	}
+	if status != pgdevserver.StatusRunning {
+		return info, nil
+	}
+	info.URL, err = server.ConnectionURL(ctx)
+	if err != nil {
+		return serverInfo{}, err
+	}
+	info.PID, err = server.PID(ctx)
+	if err != nil {
+		return serverInfo{}, err
+	}
+	return info, nil
+}
+
 type startCmd struct {
 	ServerParams serverParams `kong:"embed,group='Server Options'"`
 	CacheParams  cacheParams  `kong:"embed"`
+	FormatParams formatParams `kong:"embed"`
 }
 
 func (c *startCmd) Run() error {
 	ctx := context.Background()
-	srv, err := c.ServerParams.server(c.CacheParams.cacheDir())
+	srv, err := c.ServerParams.server(c.CacheParams)
 	if err != nil {
 		return err
 	}
@@ -128,6 +201,13 @@ func (c *startCmd) Run() error {
 		}
 		return err
 	}
+	if c.FormatParams.Format != "table" {
+		info, err := newServerInfo(ctx, srv)
+		if err != nil {
+			return err
+		}
+		return writeFormatted(os.Stdout, c.FormatParams.Format, info)
+	}
 	fmt.Println(pgURL)
 	return nil
 }
@@ -135,15 +215,24 @@ func (c *startCmd) Run() error {
 type createCmd struct {
 	ServerParams serverParams `kong:"embed,group='Server Options'"`
 	CacheParams  cacheParams  `kong:"embed"`
+	FormatParams formatParams `kong:"embed"`
 }
 
 func (c *createCmd) Run() error {
 	ctx := context.Background()
-	srv, err := c.ServerParams.server(c.CacheParams.cacheDir())
+	srv, err := c.ServerParams.server(c.CacheParams)
 	if err != nil {
 		return err
 	}
-	return srv.Create(ctx)
+	err = srv.Create(ctx)
+	if err != nil || c.FormatParams.Format == "table" {
+		return err
+	}
+	info, err := newServerInfo(ctx, srv)
+	if err != nil {
+		return err
+	}
+	return writeFormatted(os.Stdout, c.FormatParams.Format, info)
 }
 
 type stopCmd struct {
@@ -153,13 +242,41 @@ type stopCmd struct {
 
 func (c *stopCmd) Run() error {
 	ctx := context.Background()
-	srv, err := c.ServerParams.server(c.CacheParams.cacheDir())
+	srv, err := c.ServerParams.server(c.CacheParams)
 	if err != nil {
 		return err
 	}
 	return srv.Stop(ctx)
 }
 
+type restartCmd struct {
+	ServerParams serverParams `kong:"embed,group='Server Options'"`
+	CacheParams  cacheParams  `kong:"embed"`
+}
+
+func (c *restartCmd) Run() error {
+	ctx := context.Background()
+	srv, err := c.ServerParams.server(c.CacheParams)
+	if err != nil {
+		return err
+	}
+	return srv.Restart(ctx)
+}
+
+type reloadCmd struct {
+	ServerParams serverParams `kong:"embed,group='Server Options'"`
+	CacheParams  cacheParams  `kong:"embed"`
+}
+
+func (c *reloadCmd) Run() error {
+	ctx := context.Background()
+	srv, err := c.ServerParams.server(c.CacheParams)
+	if err != nil {
+		return err
+	}
+	return srv.Reload(ctx)
+}
+
 type rmServerCmd struct {
 	ID          string      `kong:"help='ID of the server to remove.'"`
 	Force       bool        `kong:"help='Remove the server even if it is running.'"`
@@ -172,6 +289,7 @@ func (c rmServerCmd) Run() error {
 	if err != nil {
 		return err
 	}
+	srv = c.CacheParams.withPGManager(srv)
 	status, err := srv.Status(ctx)
 	if err != nil {
 		status = pgdevserver.StatusUnknown
@@ -179,6 +297,23 @@ func (c rmServerCmd) Run() error {
 	if status != pgdevserver.StatusStopped && !c.Force {
 		return fmt.Errorf("server %s is not stopped. Use --force to remove it anyway", c.ID)
 	}
-	serverCache := bdcache.Cache{Root: filepath.Join(c.CacheParams.cacheDir(), "server")}
-	return serverCache.Evict(c.ID)
+	return srv.Remove(ctx)
+}
+
+type gcCmd struct {
+	MaxUnused   time.Duration `kong:"help='Also remove servers that have not been started for this long.'"`
+	DryRun      bool          `kong:"help='Show which servers would be removed without removing them.'"`
+	CacheParams cacheParams   `kong:"embed"`
+}
+
+func (c *gcCmd) Run() error {
+	removed, err := pgdevserver.GC(context.Background(), c.CacheParams.cacheDir(), pgdevserver.GCPolicy{
+		MaxUnused: c.MaxUnused,
+		DryRun:    c.DryRun,
+		PGManager: c.CacheParams.pgManager(),
+	})
+	for _, id := range removed {
+		fmt.Println(id)
+	}
+	return err
 }
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type configCmd struct {
+	Set configSetCmd `kong:"cmd,help='Persist postgres configuration parameters with ALTER SYSTEM.'"`
+}
+
+type configSetCmd struct {
+	ServerParams serverParams `kong:"embed,group='Server Options'"`
+	CacheParams  cacheParams  `kong:"embed"`
+	Restart      bool         `kong:"help='Restart the server when a parameter requires it.'"`
+	Settings     []string     `kong:"arg,help='Parameters to set as key=value.',placeholder='key=value'"`
+}
+
+func (c *configSetCmd) Run() error {
+	ctx := context.Background()
+	srv, err := c.ServerParams.server(c.CacheParams)
+	if err != nil {
+		return err
+	}
+	var needRestart []string
+	for _, setting := range c.Settings {
+		name, value, ok := strings.Cut(setting, "=")
+		if !ok {
+			return fmt.Errorf("invalid setting %q. Use key=value", setting)
+		}
+		restart, err := srv.SetParameter(ctx, strings.TrimSpace(name), value)
+		if err != nil {
+			return err
+		}
+		if restart {
+			needRestart = append(needRestart, name)
+		}
+	}
+	switch {
+	case len(needRestart) == 0:
+		return nil
+	case c.Restart:
+		return srv.Restart(ctx)
+	default:
+		_, err = fmt.Fprintf(os.Stderr, "%s will take effect when the server is restarted\n", strings.Join(needRestart, ", "))
+		return err
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/peterh/liner"
+	"github.com/willabides/pgdevserver/internal"
+)
+
+const shellHelp = `\d [NAME]   list tables, views and sequences, or describe a table
+\dt         list tables
+\dv         list views
+\di         list indexes
+\dn         list schemas
+\du         list roles
+\l          list databases
+\timing     toggle timing of commands
+\?          show this help
+\q          quit
+
+End SQL statements with a semicolon. They may span multiple lines.`
+
+type shellCmd struct {
+	ServerParams serverParams `kong:"embed,group='Server Options'"`
+	CacheParams  cacheParams  `kong:"embed"`
+	Database     string       `kong:"short='d',help='Database to connect to. Defaults to the default database.'"`
+}
+
+func (c *shellCmd) Run() (errOut error) {
+	ctx := context.Background()
+	srv, err := c.ServerParams.server(c.CacheParams)
+	if err != nil {
+		return err
+	}
+	u, err := srv.DatabaseURL(ctx, c.Database)
+	if err != nil {
+		return err
+	}
+	conn, err := pgx.Connect(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer func() { errOut = errors.Join(errOut, conn.Close(ctx)) }()
+
+	line := liner.NewLiner()
+	defer func() { errOut = errors.Join(errOut, line.Close()) }()
+	line.SetCtrlCAborts(true)
+	line.SetMultiLineMode(true)
+	historyFile := filepath.Join(c.CacheParams.cacheDir(), "shell_history")
+	err = readShellHistory(line, historyFile)
+	if err != nil {
+		return err
+	}
+	defer func() { errOut = errors.Join(errOut, writeShellHistory(line, historyFile)) }()
+
+	sh := &shell{conn: conn, out: os.Stdout}
+	fmt.Printf("Connected to %s. Type \\? for help.\n", conn.Config().Database)
+	return sh.loop(ctx, line)
+}
+
+type shell struct {
+	conn   *pgx.Conn
+	out    io.Writer
+	timing bool
+}
+
+func (sh *shell) loop(ctx context.Context, line *liner.State) error {
+	dbName := sh.conn.Config().Database
+	var buf []string
+	for {
+		prompt := dbName + "=> "
+		if len(buf) > 0 {
+			prompt = dbName + "-> "
+		}
+		input, err := line.Prompt(prompt)
+		switch {
+		case errors.Is(err, liner.ErrPromptAborted):
+			buf = nil
+			continue
+		case errors.Is(err, io.EOF):
+			fmt.Fprintln(sh.out)
+			return nil
+		case err != nil:
+			return err
+		}
+		trimmed := strings.TrimSpace(input)
+		if len(buf) == 0 && strings.HasPrefix(trimmed, `\`) {
+			line.AppendHistory(trimmed)
+			if trimmed == `\q` {
+				return nil
+			}
+			sh.printErr(sh.metaCommand(ctx, trimmed))
+			continue
+		}
+		if trimmed == "" && len(buf) == 0 {
+			continue
+		}
+		buf = append(buf, input)
+		stmt := strings.Join(buf, "\n")
+		if !statementComplete(stmt) {
+			continue
+		}
+		// liner saves one history entry per line
+		line.AppendHistory(strings.Join(buf, " "))
+		buf = nil
+		sh.printErr(sh.exec(ctx, stmt))
+	}
+}
+
+// statementComplete reports whether sql ends with a semicolon that isn't in a string, a quoted identifier, a
+// dollar-quoted string or a comment.
+func statementComplete(sql string) bool {
+	statements, rest := internal.SplitSQL(sql)
+	return len(statements) > 0 && rest == ""
+}
+
+func (sh *shell) printErr(err error) {
+	if err != nil {
+		fmt.Fprintf(sh.out, "ERROR: %v\n", err)
+	}
+}
+
+func (sh *shell) exec(ctx context.Context, stmt string) error {
+	start := time.Now()
+	err := runSQL(ctx, sh.conn, stmt, "table", sh.out)
+	if sh.timing {
+		fmt.Fprintf(sh.out, "Time: %.3f ms\n", float64(time.Since(start).Microseconds())/1000)
+	}
+	return err
+}
+
+func (sh *shell) metaCommand(ctx context.Context, input string) error {
+	cmd, arg, _ := strings.Cut(input, " ")
+	arg = strings.TrimSpace(arg)
+	switch cmd {
+	case `\?`:
+		_, err := fmt.Fprintln(sh.out, shellHelp)
+		return err
+	case `\timing`:
+		switch arg {
+		case "on":
+			sh.timing = true
+		case "off":
+			sh.timing = false
+		default:
+			sh.timing = !sh.timing
+		}
+		state := "off"
+		if sh.timing {
+			state = "on"
+		}
+		_, err := fmt.Fprintf(sh.out, "Timing is %s.\n", state)
+		return err
+	case `\d`:
+		if arg != "" {
+			return sh.describeTable(ctx, arg)
+		}
+		return sh.exec(ctx, listRelationsQuery("'r','p','v','m','S','f'"))
+	case `\dt`:
+		return sh.exec(ctx, listRelationsQuery("'r','p'"))
+	case `\dv`:
+		return sh.exec(ctx, listRelationsQuery("'v','m'"))
+	case `\di`:
+		return sh.exec(ctx, listRelationsQuery("'i','I'"))
+	case `\dn`:
+		return sh.exec(ctx, `
+			SELECT n.nspname AS "Name", pg_catalog.pg_get_userbyid(n.nspowner) AS "Owner"
+			FROM pg_catalog.pg_namespace n
+			WHERE n.nspname !~ '^pg_' AND n.nspname <> 'information_schema'
+			ORDER BY 1;`)
+	case `\du`:
+		return sh.exec(ctx, `
+			SELECT r.rolname AS "Role name", r.rolsuper AS "Superuser", r.rolcanlogin AS "Login"
+			FROM pg_catalog.pg_roles r
+			WHERE r.rolname !~ '^pg_'
+			ORDER BY 1;`)
+	case `\l`:
+		return sh.exec(ctx, `
+			SELECT d.datname AS "Name", pg_catalog.pg_get_userbyid(d.datdba) AS "Owner",
+				pg_catalog.pg_encoding_to_char(d.encoding) AS "Encoding"
+			FROM pg_catalog.pg_database d
+			ORDER BY 1;`)
+	default:
+		return fmt.Errorf(`invalid command %s. Try \? for help`, cmd)
+	}
+}
+
+// listRelationsQuery lists visible relations of the given pg_class.relkind values outside the system schemas.
+func listRelationsQuery(kinds string) string {
+	return `
+		SELECT n.nspname AS "Schema", c.relname AS "Name",
+			CASE c.relkind
+				WHEN 'r' THEN 'table' WHEN 'p' THEN 'partitioned table' WHEN 'v' THEN 'view'
+				WHEN 'm' THEN 'materialized view' WHEN 'i' THEN 'index' WHEN 'I' THEN 'partitioned index'
+				WHEN 'S' THEN 'sequence' WHEN 'f' THEN 'foreign table'
+			END AS "Type",
+			pg_catalog.pg_get_userbyid(c.relowner) AS "Owner"
+		FROM pg_catalog.pg_class c
+		LEFT JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN (` + kinds + `)
+			AND n.nspname <> 'pg_catalog' AND n.nspname <> 'information_schema' AND n.nspname !~ '^pg_toast'
+			AND pg_catalog.pg_table_is_visible(c.oid)
+		ORDER BY 1, 2;`
+}
+
+func (sh *shell) describeTable(ctx context.Context, name string) error {
+	rel := quoteLiteral(name) + "::pg_catalog.regclass"
+	err := sh.exec(ctx, `
+		SELECT a.attname AS "Column", pg_catalog.format_type(a.atttypid, a.atttypmod) AS "Type",
+			CASE WHEN a.attnotnull THEN 'not null' ELSE '' END AS "Nullable",
+			COALESCE(pg_catalog.pg_get_expr(d.adbin, d.adrelid), '') AS "Default"
+		FROM pg_catalog.pg_attribute a
+		LEFT JOIN pg_catalog.pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE a.attrelid = `+rel+` AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attnum;`)
+	if err != nil {
+		return err
+	}
+	return sh.exec(ctx, `
+		SELECT i.relname AS "Index", pg_catalog.pg_get_indexdef(i.oid) AS "Definition"
+		FROM pg_catalog.pg_index x
+		JOIN pg_catalog.pg_class i ON i.oid = x.indexrelid
+		WHERE x.indrelid = `+rel+`
+		ORDER BY 1;`)
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func readShellHistory(line *liner.State, filename string) (errOut error) {
+	f, err := os.Open(filename)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	}
+	defer func() { errOut = errors.Join(errOut, f.Close()) }()
+	_, err = line.ReadHistory(f)
+	return err
+}
+
+func writeShellHistory(line *liner.State, filename string) (errOut error) {
+	err := os.MkdirAll(filepath.Dir(filename), 0o700)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer func() { errOut = errors.Join(errOut, f.Close()) }()
+	_, err = line.WriteHistory(f)
+	return err
+}
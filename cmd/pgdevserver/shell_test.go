@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_statementComplete(t *testing.T) {
+	for _, tc := range []struct {
+		sql  string
+		want bool
+	}{
+		{sql: "SELECT 1;", want: true},
+		{sql: "SELECT 1", want: false},
+		{sql: "SELECT 1;  \n", want: true},
+		{sql: "SELECT 1; -- done", want: true},
+		{sql: "SELECT 1 -- not done;", want: false},
+		{sql: "SELECT 1 /* ; */", want: false},
+		{sql: "SELECT 1 /* /* ; */ */;", want: true},
+		{sql: "SELECT 1; SELECT", want: false},
+		{sql: "SELECT 'a;", want: false},
+		{sql: "SELECT 'a;\nb';", want: true},
+		{sql: "SELECT 'it''s;", want: false},
+		{sql: "SELECT 'it''s';", want: true},
+		{sql: `SELECT E'it\'s;`, want: false},
+		{sql: `SELECT E'it\'s';`, want: true},
+		{sql: `SELECT 'a\';`, want: true},
+		{sql: `SELECT "a;b`, want: false},
+		{sql: `SELECT "a;b" FROM t;`, want: true},
+		{sql: "CREATE FUNCTION f() RETURNS int AS $$\nBEGIN\n  RETURN 1;", want: false},
+		{sql: "CREATE FUNCTION f() RETURNS int AS $$\nBEGIN\n  RETURN 1;\nEND;\n$$ LANGUAGE plpgsql;", want: true},
+		{sql: "SELECT $body$ $$; $body$", want: false},
+		{sql: "SELECT $body$ $$; $body$;", want: true},
+		{sql: "SELECT $1;", want: true},
+		{sql: "SELECT a$b$ FROM t;", want: true},
+	} {
+		require.Equal(t, tc.want, statementComplete(tc.sql), tc.sql)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+type snapshotCmd struct {
+	Save    snapshotSaveCmd    `kong:"cmd,help='Save a snapshot of a server.'"`
+	Restore snapshotRestoreCmd `kong:"cmd,help='Restore a server from a snapshot.'"`
+	List    snapshotListCmd    `kong:"cmd,help='List snapshots of a server.'"`
+	Rm      snapshotRmCmd      `kong:"cmd,help='Remove a snapshot.'"`
+}
+
+type snapshotSaveCmd struct {
+	ServerParams serverParams `kong:"embed,group='Server Options'"`
+	CacheParams  cacheParams  `kong:"embed"`
+	Name         string       `kong:"arg,help='Name of the snapshot.'"`
+}
+
+func (c *snapshotSaveCmd) Run() error {
+	srv, err := c.ServerParams.server(c.CacheParams)
+	if err != nil {
+		return err
+	}
+	return srv.Snapshot(context.Background(), c.Name)
+}
+
+type snapshotRestoreCmd struct {
+	ServerParams serverParams `kong:"embed,group='Server Options'"`
+	CacheParams  cacheParams  `kong:"embed"`
+	Name         string       `kong:"arg,help='Name of the snapshot.'"`
+}
+
+func (c *snapshotRestoreCmd) Run() error {
+	srv, err := c.ServerParams.server(c.CacheParams)
+	if err != nil {
+		return err
+	}
+	return srv.Restore(context.Background(), c.Name)
+}
+
+type snapshotListCmd struct {
+	ServerParams serverParams `kong:"embed,group='Server Options'"`
+	CacheParams  cacheParams  `kong:"embed"`
+}
+
+func (c *snapshotListCmd) Run() error {
+	srv, err := c.ServerParams.server(c.CacheParams)
+	if err != nil {
+		return err
+	}
+	names, err := srv.Snapshots(context.Background())
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+type snapshotRmCmd struct {
+	ServerParams serverParams `kong:"embed,group='Server Options'"`
+	CacheParams  cacheParams  `kong:"embed"`
+	Name         string       `kong:"arg,help='Name of the snapshot.'"`
+}
+
+func (c *snapshotRmCmd) Run() error {
+	srv, err := c.ServerParams.server(c.CacheParams)
+	if err != nil {
+		return err
+	}
+	return srv.RemoveSnapshot(context.Background(), c.Name)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type sqlCmd struct {
+	ServerParams serverParams `kong:"embed,group='Server Options'"`
+	CacheParams  cacheParams  `kong:"embed"`
+	File         string       `kong:"short='f',type='existingfile',xor='query',help='Read SQL from this file.'"`
+	Database     string       `kong:"short='d',help='Database to connect to. Defaults to the default database.'"`
+	Format       string       `kong:"default='table',enum='table,csv,json',help='Output format. One of table, csv or json.'"`
+	Query        string       `kong:"arg,optional,xor='query',help='SQL to run.'"`
+}
+
+func (c *sqlCmd) Run() (errOut error) {
+	ctx := context.Background()
+	query := c.Query
+	if c.File != "" {
+		b, err := os.ReadFile(c.File)
+		if err != nil {
+			return err
+		}
+		query = string(b)
+	}
+	if strings.TrimSpace(query) == "" {
+		return errors.New("no SQL to run. Pass a query or use --file")
+	}
+	srv, err := c.ServerParams.server(c.CacheParams)
+	if err != nil {
+		return err
+	}
+	u, err := srv.DatabaseURL(ctx, c.Database)
+	if err != nil {
+		return err
+	}
+	conn, err := pgx.Connect(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer func() { errOut = errors.Join(errOut, conn.Close(ctx)) }()
+	return runSQL(ctx, conn, query, c.Format, os.Stdout)
+}
+
+// runSQL runs one or more statements and writes each result to w in the given format.
+func runSQL(ctx context.Context, conn *pgx.Conn, query, format string, w io.Writer) error {
+	results, err := conn.PgConn().Exec(ctx, query).ReadAll()
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			return result.Err
+		}
+		switch format {
+		case "csv":
+			err = writeCSVResult(w, result)
+		case "json":
+			err = writeJSONResult(w, conn.TypeMap(), result)
+		default:
+			err = writeTableResult(w, result)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const (
+	// tableNull is how NULL is shown in table output, where an empty string is shown as nothing.
+	tableNull = "NULL"
+	// csvNull is how NULL is written in csv output. It matches the default NULL string of COPY's text format.
+	csvNull = `\N`
+)
+
+// textValue returns a text format value as a string or null if it is NULL.
+func textValue(v []byte, null string) string {
+	if v == nil {
+		return null
+	}
+	return string(v)
+}
+
+// writeTableResult writes rows as an aligned table followed by the command tag.
+func writeTableResult(w io.Writer, result *pgconn.Result) (errOut error) {
+	if len(result.FieldDescriptions) > 0 {
+		tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+		header := make([]string, len(result.FieldDescriptions))
+		rule := make([]string, len(result.FieldDescriptions))
+		for i, fd := range result.FieldDescriptions {
+			header[i] = fd.Name
+			rule[i] = strings.Repeat("-", len(fd.Name))
+		}
+		_, err := fmt.Fprintln(tw, strings.Join(header, "\t| "))
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(tw, strings.Join(rule, "\t+-"))
+		if err != nil {
+			return err
+		}
+		for _, row := range result.Rows {
+			line := make([]string, len(row))
+			for i, v := range row {
+				line[i] = textValue(v, tableNull)
+			}
+			_, err = fmt.Fprintln(tw, strings.Join(line, "\t| "))
+			if err != nil {
+				return err
+			}
+		}
+		err = tw.Flush()
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, result.CommandTag.String())
+	return err
+}
+
+// writeCSVResult writes a header row and the rows of result. NULL is written as \N to tell it apart from an empty
+// string. Results without columns are skipped.
+func writeCSVResult(w io.Writer, result *pgconn.Result) error {
+	if len(result.FieldDescriptions) == 0 {
+		return nil
+	}
+	cw := csv.NewWriter(w)
+	header := make([]string, len(result.FieldDescriptions))
+	for i, fd := range result.FieldDescriptions {
+		header[i] = fd.Name
+	}
+	err := cw.Write(header)
+	if err != nil {
+		return err
+	}
+	for _, row := range result.Rows {
+		line := make([]string, len(row))
+		for i, v := range row {
+			line[i] = textValue(v, csvNull)
+		}
+		err = cw.Write(line)
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeJSONResult writes the rows of result as a JSON array of objects. Results without columns are skipped.
+func writeJSONResult(w io.Writer, typeMap *pgtype.Map, result *pgconn.Result) error {
+	if len(result.FieldDescriptions) == 0 {
+		return nil
+	}
+	rows := make([]map[string]any, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		obj := make(map[string]any, len(row))
+		for i, fd := range result.FieldDescriptions {
+			v, err := decodeTextValue(typeMap, fd.DataTypeOID, row[i])
+			if err != nil {
+				return err
+			}
+			obj[fd.Name] = v
+		}
+		rows = append(rows, obj)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
+
+// decodeTextValue decodes a text format value into a go value, falling back to a string for unknown types.
+func decodeTextValue(typeMap *pgtype.Map, oid uint32, src []byte) (any, error) {
+	if src == nil {
+		return nil, nil
+	}
+	typ, ok := typeMap.TypeForOID(oid)
+	if !ok {
+		return string(src), nil
+	}
+	return typ.Codec.DecodeValue(typeMap, oid, pgtype.TextFormatCode, src)
+}
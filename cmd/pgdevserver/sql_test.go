@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+// testResult is the result of SELECT id, name FROM people with a NULL name and an empty one.
+func testResult() *pgconn.Result {
+	return &pgconn.Result{
+		FieldDescriptions: []pgconn.FieldDescription{
+			{Name: "id", DataTypeOID: pgtype.Int4OID},
+			{Name: "name", DataTypeOID: pgtype.TextOID},
+		},
+		Rows: [][][]byte{
+			{[]byte("1"), []byte("alice")},
+			{[]byte("2"), nil},
+			{[]byte("3"), []byte("")},
+		},
+		CommandTag: pgconn.NewCommandTag("SELECT 3"),
+	}
+}
+
+func Test_writeTableResult(t *testing.T) {
+	t.Run("rows", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, writeTableResult(&buf, testResult()))
+		require.Equal(t, ""+
+			"id | name\n"+
+			"-- +-----\n"+
+			"1  | alice\n"+
+			"2  | NULL\n"+
+			"3  | \n"+
+			"SELECT 3\n", buf.String())
+	})
+
+	t.Run("no columns", func(t *testing.T) {
+		var buf bytes.Buffer
+		result := &pgconn.Result{CommandTag: pgconn.NewCommandTag("CREATE TABLE")}
+		require.NoError(t, writeTableResult(&buf, result))
+		require.Equal(t, "CREATE TABLE\n", buf.String())
+	})
+}
+
+func Test_writeCSVResult(t *testing.T) {
+	t.Run("rows", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, writeCSVResult(&buf, testResult()))
+		require.Equal(t, "id,name\n1,alice\n2,\\N\n3,\n", buf.String())
+	})
+
+	t.Run("no columns", func(t *testing.T) {
+		var buf bytes.Buffer
+		result := &pgconn.Result{CommandTag: pgconn.NewCommandTag("CREATE TABLE")}
+		require.NoError(t, writeCSVResult(&buf, result))
+		require.Empty(t, buf.String())
+	})
+}
+
+func Test_writeJSONResult(t *testing.T) {
+	t.Run("rows", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, writeJSONResult(&buf, pgtype.NewMap(), testResult()))
+		require.JSONEq(t, `[
+			{"id": 1, "name": "alice"},
+			{"id": 2, "name": null},
+			{"id": 3, "name": ""}
+		]`, buf.String())
+	})
+
+	t.Run("no rows", func(t *testing.T) {
+		var buf bytes.Buffer
+		result := testResult()
+		result.Rows = nil
+		require.NoError(t, writeJSONResult(&buf, pgtype.NewMap(), result))
+		require.JSONEq(t, `[]`, buf.String())
+	})
+
+	t.Run("no columns", func(t *testing.T) {
+		var buf bytes.Buffer
+		result := &pgconn.Result{CommandTag: pgconn.NewCommandTag("CREATE TABLE")}
+		require.NoError(t, writeJSONResult(&buf, pgtype.NewMap(), result))
+		require.Empty(t, buf.String())
+	})
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+type upgradeCmd struct {
+	ServerParams serverParams `kong:"embed,group='Server Options'"`
+	CacheParams  cacheParams  `kong:"embed"`
+	To           string       `kong:"required,help='Postgres version to upgrade to.'"`
+}
+
+func (c *upgradeCmd) Run() error {
+	srv, err := c.ServerParams.server(c.CacheParams)
+	if err != nil {
+		return err
+	}
+	upgraded, err := srv.Upgrade(context.Background(), c.To)
+	if err != nil {
+		return err
+	}
+	fmt.Println(upgraded.ID())
+	return nil
+}
@@ -1,14 +1,20 @@
 package pgdevserver
 
 import (
+	"cmp"
 	"crypto/sha256"
 	"fmt"
+	"io/fs"
 	"slices"
 	"strings"
+	"time"
 )
 
 type Config struct {
-	// PostgresVersion is the version of the postgres binaries to use. Default is "17.2.0"
+	// PostgresVersion is the version of the postgres binaries to use. It may also be a constraint like "17",
+	// "~16.4" or ">=15 <17", or "latest", which are resolved to an exact version when the server is initialized.
+	// A constraint resolves to the version of an existing server that matches it and has the same settings,
+	// otherwise to a version from PGManager.ResolveVersion. Default is "latest".
 	PostgresVersion string `json:"postgres_version,omitempty"`
 
 	// CacheDir is the directory containing the cache. Default is pgdevserver under the xdg cache directory
@@ -24,11 +30,51 @@ type Config struct {
 	// InitDBArgs are additional arguments to pass to initdb when creating the cluster.
 	InitDBArgs []string `json:"init_db_args,omitempty"`
 
-	// Port is the port to use for the cluster. If empty, a random port will be selected.
+	// Port is the port to use for the cluster. If empty, a random port will be selected, or 5432 when
+	// DisableTCP is set.
 	Port string `json:"port,omitempty"`
 
+	// SocketDir is the directory where postgres creates its unix domain socket. Default is the postgres default,
+	// or a directory in the server's cache when DisableTCP is set.
+	SocketDir string `json:"socket_dir,omitempty"`
+
+	// DisableTCP stops the server from listening on TCP. Clients connect through the unix domain socket in
+	// SocketDir instead.
+	DisableTCP bool `json:"disable_tcp,omitempty"`
+
+	// User is the name of the database superuser. Default is "postgres".
+	User string `json:"user,omitempty"`
+
+	// Password is the superuser's password. It is required when AuthMethod is md5 or scram-sha-256.
+	Password string `json:"password,omitempty"`
+
+	// AuthMethod is the authentication method for local and host connections. One of "trust", "md5" or
+	// "scram-sha-256". Default is "trust".
+	AuthMethod string `json:"auth_method,omitempty"`
+
+	// TLS enables TLS connections using a certificate signed by a CA generated in the server's cache.
+	TLS bool `json:"tls,omitempty"`
+
+	// IdleTimeout stops the server after it has had no client connections for this long. Start spawns a
+	// watcher process to enforce it, which requires calling RunIdleWatcher at the start of main. Zero disables the
+	// watcher. Changing IdleTimeout does not create a new server.
+	IdleTimeout time.Duration `json:"idle_timeout,omitempty"`
+
+	// TTL is how long the server should be kept after it is created. GC removes servers that have outlived their TTL.
+	// Zero means the server never expires. The TTL in effect is the one the server was created with.
+	TTL time.Duration `json:"ttl,omitempty"`
+
+	// InitScripts contains .sql, .sql.gz and .sh files to run in lexical order the first time a newly created server
+	// is started, like docker-entrypoint-initdb.d in the postgres docker image. Shell scripts run with sh and get
+	// the PG* connection environment variables. Use InitScriptsDir to run scripts from a directory.
+	InitScripts fs.FS `json:"-"`
+
+	// InitScriptsDir is a directory of init scripts that is used when InitScripts is nil. Unlike InitScripts, it is
+	// saved with the server, so a server loaded with ServerFromCache still runs scripts that are pending.
+	InitScriptsDir string `json:"init_scripts_dir,omitempty"`
+
 	// PGManager is the PGManager to use for installing postgres. If nil, a default PGManager will be used.
-	PGManager *PGManager
+	PGManager *PGManager `json:"-"`
 }
 
 func (c Config) clone() Config {
@@ -52,5 +98,49 @@ func (c Config) cacheKey() string {
 		h.Write([]byte{0})
 		h.Write([]byte(kv[1]))
 	}
+	// Fields added after the first release are only hashed when set so that existing servers keep their IDs.
+	for _, kv := range [][2]string{
+		{"SocketDir", c.SocketDir},
+		{"DisableTCP", boolKey(c.DisableTCP)},
+		{"User", c.User},
+		{"Password", c.Password},
+		{"AuthMethod", c.AuthMethod},
+		{"TLS", boolKey(c.TLS)},
+	} {
+		if kv[1] == "" {
+			continue
+		}
+		h.Write([]byte(kv[0]))
+		h.Write([]byte{0})
+		h.Write([]byte(kv[1]))
+	}
 	return fmt.Sprintf("%s-%x", c.Name, h.Sum(nil)[:keyWidth])
 }
+
+// user returns the superuser name.
+func (c Config) user() string {
+	return cmp.Or(c.User, "postgres")
+}
+
+// validateAuthMethod checks that method is supported and that a password is set when method requires one.
+func validateAuthMethod(method, password string) error {
+	switch method {
+	case "", "trust":
+		return nil
+	case "md5", "scram-sha-256":
+		if password == "" {
+			return fmt.Errorf("auth method %s requires a password", method)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported auth method %q", method)
+	}
+}
+
+// boolKey returns the cache key representation of b.
+func boolKey(b bool) string {
+	if b {
+		return "true"
+	}
+	return ""
+}
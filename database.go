@@ -0,0 +1,73 @@
+package pgdevserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DatabaseOptions are options for creating a database with Server.CreateDatabase.
+type DatabaseOptions struct {
+	// Template is the name of an existing database to clone. Default is postgres' default template (template1).
+	Template string
+}
+
+// CreateDatabase creates a database on the running server and returns a connection URL for it.
+func (s *Server) CreateDatabase(ctx context.Context, name string, opts *DatabaseOptions) (string, error) {
+	s.init()
+	if name == "" {
+		return "", errors.New("database name is required")
+	}
+	if opts == nil {
+		opts = &DatabaseOptions{}
+	}
+	stmt := "CREATE DATABASE " + pgx.Identifier{name}.Sanitize()
+	if opts.Template != "" {
+		stmt += " TEMPLATE " + pgx.Identifier{opts.Template}.Sanitize()
+	}
+	err := s.execAdmin(ctx, stmt)
+	if err != nil {
+		return "", fmt.Errorf("creating database %s: %w", name, err)
+	}
+	return s.DatabaseURL(ctx, name)
+}
+
+// DropDatabase drops a database from the running server. It is not an error if the database does not exist.
+func (s *Server) DropDatabase(ctx context.Context, name string) error {
+	s.init()
+	if name == "" {
+		return errors.New("database name is required")
+	}
+	err := s.execAdmin(ctx, "DROP DATABASE IF EXISTS "+pgx.Identifier{name}.Sanitize())
+	if err != nil {
+		return fmt.Errorf("dropping database %s: %w", name, err)
+	}
+	return nil
+}
+
+// DatabaseURL returns a connection URL for the named database on this server.
+func (s *Server) DatabaseURL(ctx context.Context, name string) (string, error) {
+	s.init()
+	params, err := s.connParams(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return params.url(), nil
+}
+
+// execAdmin runs a single statement against the server's default database.
+func (s *Server) execAdmin(ctx context.Context, stmt string) (errOut error) {
+	u, err := s.ConnectionURL(ctx)
+	if err != nil {
+		return err
+	}
+	conn, err := pgx.Connect(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer func() { errOut = errors.Join(errOut, conn.Close(ctx)) }()
+	_, err = conn.Exec(ctx, stmt)
+	return err
+}
@@ -1,7 +1,6 @@
 package pgdevserver
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -19,37 +18,165 @@ import (
 
 const zonkyGroupID = "io/zonky/test/postgres"
 
-// download downloads the pg jar file and returns its content.
-func (m *PGManager) download(ctx context.Context, mavenURL, version string) (_ []byte, errOut error) {
+// downloadPath is where the jar for version is downloaded before it is extracted. It is outside the version's
+// cache entry, which is removed when population fails.
+func (m *PGManager) downloadPath(version string) string {
+	return filepath.Join(m.config.CacheDir, ".downloads", pgCacheKey(version)+".jar.partial")
+}
+
+// download downloads the pg jar file to filename and verifies it against the repository's published checksum.
+// It returns the verified digest. When filename already holds part of the jar from an interrupted download, only
+// the rest is requested. If the resumed jar doesn't match the checksum, it is downloaded again from the start.
+func (m *PGManager) download(ctx context.Context, mavenURL, version, filename string) (string, error) {
 	system := fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH)
 	artifactID, err := m.getArtifactID(ctx, mavenURL, system, version)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	u := fmt.Sprintf(
 		"%s/%s/%s/%s/%s-%s.jar",
 		mavenURL, zonkyGroupID, artifactID, version, artifactID, version,
 	)
+	_, err = os.Stat(filename)
+	resumed := err == nil
+	digest, err := m.downloadVerified(ctx, u, version, filename)
+	if resumed && errors.Is(err, errChecksumMismatch) {
+		// the partial file was corrupt or from a different jar
+		digest, err = m.downloadVerified(ctx, u, version, filename)
+	}
+	return digest, err
+}
+
+// downloadVerified downloads u to filename and verifies it, removing filename if it doesn't match the checksum.
+func (m *PGManager) downloadVerified(ctx context.Context, u, version, filename string) (string, error) {
+	err := m.downloadJar(ctx, u, version, filename)
+	if err != nil {
+		return "", err
+	}
+	digest, err := m.verifyChecksum(ctx, u, filename)
+	if errors.Is(err, errChecksumMismatch) {
+		// start over next time
+		err = errors.Join(err, os.Remove(filename))
+	}
+	return digest, err
+}
+
+// downloadJar downloads u to filename, resuming from the end of filename if it exists.
+func (m *PGManager) downloadJar(ctx context.Context, u, version, filename string) (errOut error) {
+	var offset int64
+	info, err := os.Stat(filename)
+	switch {
+	case err == nil:
+		offset = info.Size()
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
 	}
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := m.config.HTTPClient.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer func() { errOut = errors.Join(errOut, resp.Body.Close()) }()
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected http status code %d", resp.StatusCode)
+	total := resp.ContentLength
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		var start, end int64
+		contentRange := resp.Header.Get("Content-Range")
+		_, err = fmt.Sscanf(contentRange, "bytes %d-%d/%d", &start, &end, &total)
+		if err != nil || start != offset {
+			return fmt.Errorf("unexpected Content-Range %q for offset %d", contentRange, offset)
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial file is already complete. The checksum will catch it if it isn't.
+		return nil
+	default:
+		err = checkMavenResponse(resp)
+		if err != nil {
+			return err
+		}
+		// the server ignored the range, so start from the beginning
+		offset = 0
+	}
+	body := m.newProgressReader(resp.Body, version, ProgressDownload, total)
+	body.progress.Done = offset
+	err = writeDownload(filename, offset, body)
+	if err != nil {
+		return err
+	}
+	body.finish()
+	return nil
+}
+
+// writeDownload writes r to filename starting at offset.
+func writeDownload(filename string, offset int64, r io.Reader) (errOut error) {
+	err := os.MkdirAll(filepath.Dir(filename), 0o700)
+	if err != nil {
+		return err
+	}
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flag = os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(filename, flag, 0o600)
+	if err != nil {
+		return err
+	}
+	defer func() { errOut = errors.Join(errOut, f.Close()) }()
+	_, err = io.Copy(f, r)
+	return err
+}
+
+// progressReader reports progress to the configured Progress callback after each read.
+type progressReader struct {
+	r        io.Reader
+	progress Progress
+	report   func(Progress)
+}
+
+func (m *PGManager) newProgressReader(r io.Reader, version string, phase ProgressPhase, total int64) *progressReader {
+	return &progressReader{
+		r: r,
+		progress: Progress{
+			Version: version,
+			Phase:   phase,
+			Total:   total,
+		},
+		report: m.config.Progress,
+	}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.progress.Done += int64(n)
+	if p.report != nil && n > 0 {
+		p.report(p.progress)
 	}
-	return io.ReadAll(resp.Body)
+	return n, err
+}
+
+// finish reports the phase as complete. Readers don't always read to the end, and the total isn't always known.
+func (p *progressReader) finish() {
+	if p.report == nil {
+		return
+	}
+	if p.progress.Total < 0 {
+		p.progress.Total = p.progress.Done
+	}
+	p.progress.Done = p.progress.Total
+	p.report(p.progress)
 }
 
 // extractJar double extracts the pg jar file. The jar file contains a single txz
 // file which contains the actual pg binaries. extractJar extracts the txz file
 // to the dest directory.
-func extractJar(ctx context.Context, dest string, content []byte) (errOut error) {
-	jarFS, err := archives.FileSystem(ctx, "", bytes.NewReader(content))
+func (m *PGManager) extractJar(ctx context.Context, dest, jarFile, version string) (errOut error) {
+	jarFS, err := archives.FileSystem(ctx, jarFile, nil)
 	if err != nil {
 		return err
 	}
@@ -64,13 +191,18 @@ func extractJar(ctx context.Context, dest string, content []byte) (errOut error)
 	if len(txzFiles) != 1 {
 		return fmt.Errorf("expected 1 txz file, got %d", len(txzFiles))
 	}
+	txzInfo, err := txzFiles[0].Info()
+	if err != nil {
+		return err
+	}
 	txzFilename := txzFiles[0].Name()
 	txzFile, err := jarFS.Open(txzFilename)
 	if err != nil {
 		return err
 	}
 	defer func() { errOut = errors.Join(errOut, txzFile.Close()) }()
-	txzFormat, txzReader, err := archives.Identify(ctx, txzFilename, txzFile)
+	txz := m.newProgressReader(txzFile, version, ProgressExtract, txzInfo.Size())
+	txzFormat, txzReader, err := archives.Identify(ctx, txzFilename, txz)
 	if err != nil {
 		return err
 	}
@@ -79,9 +211,14 @@ func extractJar(ctx context.Context, dest string, content []byte) (errOut error)
 		return fmt.Errorf("txz file is not an extractor")
 	}
 
-	return txzExtractor.Extract(ctx, txzReader, func(_ context.Context, info archives.FileInfo) error {
+	err = txzExtractor.Extract(ctx, txzReader, func(_ context.Context, info archives.FileInfo) error {
 		return handleTxzExtractFile(info, dest)
 	})
+	if err != nil {
+		return err
+	}
+	txz.finish()
+	return nil
 }
 
 func handleTxzExtractFile(info archives.FileInfo, destRoot string) (errOut error) {
@@ -96,8 +233,14 @@ func handleTxzExtractFile(info archives.FileInfo, destRoot string) (errOut error
 		return fmt.Errorf("illegal file path: %s", dest)
 	}
 
+	// An earlier entry may have been a symlink pointing anywhere. Following it would write outside destRoot.
+	err := checkNoSymlinks(destRoot, dest)
+	if err != nil {
+		return err
+	}
+
 	parentDir := filepath.Dir(dest)
-	err := os.MkdirAll(parentDir, 0o700)
+	err = os.MkdirAll(parentDir, 0o700)
 	if err != nil {
 		return err
 	}
@@ -107,6 +250,13 @@ func handleTxzExtractFile(info archives.FileInfo, destRoot string) (errOut error
 	}
 
 	if info.LinkTarget != "" {
+		target := filepath.Join(filepath.Dir(dest), info.LinkTarget)
+		if filepath.IsAbs(info.LinkTarget) || !strings.HasPrefix(
+			target+string(os.PathSeparator),
+			filepath.Clean(destRoot)+string(os.PathSeparator),
+		) {
+			return fmt.Errorf("illegal symlink target: %s -> %s", dest, info.LinkTarget)
+		}
 		return os.Symlink(info.LinkTarget, dest)
 	}
 
@@ -125,3 +275,26 @@ func handleTxzExtractFile(info archives.FileInfo, destRoot string) (errOut error
 	_, err = io.Copy(dstFile, file)
 	return err
 }
+
+// checkNoSymlinks returns an error if dest or any directory between destRoot and dest is a symlink.
+func checkNoSymlinks(destRoot, dest string) error {
+	rel, err := filepath.Rel(destRoot, dest)
+	if err != nil {
+		return err
+	}
+	path := destRoot
+	for _, name := range strings.Split(rel, string(os.PathSeparator)) {
+		path = filepath.Join(path, name)
+		info, err := os.Lstat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("illegal file path through symlink: %s", path)
+		}
+	}
+	return nil
+}
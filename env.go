@@ -0,0 +1,14 @@
+package pgdevserver
+
+import "context"
+
+// Env returns environment variables for connecting to the server: DATABASE_URL and the libpq variables PGHOST,
+// PGPORT, PGUSER and PGDATABASE, plus PGPASSWORD, PGSSLMODE and PGSSLROOTCERT when they apply.
+func (s *Server) Env(ctx context.Context) (map[string]string, error) {
+	s.init()
+	params, err := s.connParams(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return params.env(), nil
+}
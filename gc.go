@@ -0,0 +1,84 @@
+package pgdevserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// GCPolicy controls which servers GC removes.
+type GCPolicy struct {
+	// MaxUnused removes servers that have not been started for this long. Running servers are never considered
+	// unused. Zero disables this check.
+	MaxUnused time.Duration
+
+	// DryRun reports the servers that would be removed without removing them.
+	DryRun bool
+
+	// PGManager is used to run the postgres binaries of the servers being collected. Default is the PGManager
+	// each server was created with, which is a default PGManager for servers loaded from the cache.
+	PGManager *PGManager
+}
+
+// GC stops and removes servers in cacheDir that have outlived their Config.TTL or that are unused according to
+// policy. It returns the IDs of the removed servers. A server that can't be checked or removed doesn't stop the
+// others from being collected. Its error is included in the returned error.
+func GC(ctx context.Context, cacheDir string, policy GCPolicy) ([]string, error) {
+	servers, err := ServersFromCache(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var removed []string
+	var errs []error
+	for _, server := range servers {
+		if policy.PGManager != nil {
+			cfg := server.Config()
+			cfg.PGManager = policy.PGManager
+			server = New(cfg)
+		}
+		// gc must not populate incomplete servers just to check them
+		server.existingOnly = true
+		expired, err := server.gcExpired(ctx, now, policy)
+		if err == nil && expired && !policy.DryRun {
+			err = server.Remove(ctx)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", server.ID(), err))
+			continue
+		}
+		if expired {
+			removed = append(removed, server.ID())
+		}
+	}
+	return removed, errors.Join(errs...)
+}
+
+func (s *Server) gcExpired(ctx context.Context, now time.Time, policy GCPolicy) (bool, error) {
+	created, err := s.CreatedAt(ctx)
+	if err != nil {
+		return false, err
+	}
+	if s.config.TTL > 0 && now.Sub(created) > s.config.TTL {
+		return true, nil
+	}
+	if policy.MaxUnused <= 0 {
+		return false, nil
+	}
+	status, err := s.Status(ctx)
+	if err != nil {
+		return false, err
+	}
+	if status == StatusRunning {
+		return false, nil
+	}
+	lastUsed, err := s.LastStarted(ctx)
+	if err != nil {
+		return false, err
+	}
+	if lastUsed.IsZero() {
+		lastUsed = created
+	}
+	return now.Sub(lastUsed) > policy.MaxUnused, nil
+}
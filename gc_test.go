@@ -0,0 +1,177 @@
+package pgdevserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServerCache writes a server cache entry that looks populated without running initdb.
+func fakeServerCache(t *testing.T, cacheDir string, cfg Config, created time.Time) *Server {
+	t.Helper()
+	cfg.CacheDir = cacheDir
+	srv := New(cfg)
+	dir := filepath.Join(cacheDir, "server", srv.ID())
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "data"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "data", "PG_VERSION"), []byte("17\n"), 0o600))
+	require.NoError(t, srv.writeConfigFile(dir))
+	require.NoError(t, writeTimestamp(createdAtPath(dir), created))
+	return srv
+}
+
+func TestGC(t *testing.T) {
+	ctx := context.Background()
+	cacheDir := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+	expired := fakeServerCache(t, cacheDir, Config{Name: "expired", TTL: time.Hour}, old)
+	fakeServerCache(t, cacheDir, Config{Name: "fresh", TTL: time.Hour}, time.Now())
+	fakeServerCache(t, cacheDir, Config{Name: "forever"}, old)
+
+	removed, err := GC(ctx, cacheDir, GCPolicy{DryRun: true})
+	require.NoError(t, err)
+	require.Equal(t, []string{expired.ID()}, removed)
+	servers, err := ServersFromCache(cacheDir)
+	require.NoError(t, err)
+	require.Len(t, servers, 3)
+}
+
+// fakePGInstall installs a fake pg_ctl for version in cacheDir's postgres cache. Servers are stopped until it is
+// used to start them.
+func fakePGInstall(t *testing.T, cacheDir, version string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake binaries are shell scripts")
+	}
+	dir := filepath.Join(cacheDir, "postgres", pgCacheKey(version))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "bin"), 0o700))
+	script := `#!/bin/sh
+cmd=$1
+shift
+while [ $# -gt 0 ]; do
+  case $1 in -D | --pgdata) data=$2 ;; esac
+  shift
+done
+case $cmd in
+status) [ -f "$data/fake_running" ] || exit 3 ;;
+start) touch "$data/fake_running" ;;
+stop) rm -f "$data/fake_running" ;;
+esac
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bin", "pg_ctl"), []byte(script), 0o700))
+	require.NoError(t, os.WriteFile(versionFile(dir), []byte(version+"\n"), 0o600))
+}
+
+func TestGC_MaxUnused(t *testing.T) {
+	ctx := context.Background()
+	cacheDir := t.TempDir()
+	fakePGInstall(t, cacheDir, "17.1.0")
+	old := time.Now().Add(-48 * time.Hour)
+	neverStarted := fakeServerCache(t, cacheDir, Config{PostgresVersion: "17.1.0", Name: "never-started"}, old)
+	unused := fakeServerCache(t, cacheDir, Config{PostgresVersion: "17.1.0", Name: "unused"}, old)
+	dir := filepath.Join(cacheDir, "server", unused.ID())
+	require.NoError(t, writeTimestamp(lastStartedPath(dir), time.Now().Add(-25*time.Hour)))
+	recent := fakeServerCache(t, cacheDir, Config{PostgresVersion: "17.1.0", Name: "recent"}, old)
+	dir = filepath.Join(cacheDir, "server", recent.ID())
+	require.NoError(t, writeTimestamp(lastStartedPath(dir), time.Now().Add(-time.Hour)))
+
+	removed, err := GC(ctx, cacheDir, GCPolicy{MaxUnused: 24 * time.Hour, DryRun: true})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{neverStarted.ID(), unused.ID()}, removed)
+}
+
+func TestGC_PGManager(t *testing.T) {
+	ctx := context.Background()
+	cacheDir := t.TempDir()
+	// postgres is only installed in the policy's PGManager cache
+	pgRoot := t.TempDir()
+	fakePGInstall(t, pgRoot, "17.1.0")
+	pgm := NewPGManager(PGMConfig{CacheDir: filepath.Join(pgRoot, "postgres")})
+	unused := fakeServerCache(t, cacheDir, Config{PostgresVersion: "17.1.0", Name: "unused", PGManager: pgm},
+		time.Now().Add(-48*time.Hour))
+
+	removed, err := GC(ctx, cacheDir, GCPolicy{MaxUnused: 24 * time.Hour, PGManager: pgm})
+	require.NoError(t, err)
+	require.Equal(t, []string{unused.ID()}, removed)
+}
+
+func TestGC_remove(t *testing.T) {
+	ctx := context.Background()
+	cacheDir := t.TempDir()
+	fakePGInstall(t, cacheDir, "17.1.0")
+	old := time.Now().Add(-48 * time.Hour)
+	expired := fakeServerCache(t, cacheDir, Config{PostgresVersion: "17.1.0", Name: "expired", TTL: time.Hour}, old)
+	fresh := fakeServerCache(t, cacheDir, Config{PostgresVersion: "17.1.0", Name: "fresh", TTL: time.Hour}, time.Now())
+	// an incomplete entry can't be checked but doesn't stop the others from being removed
+	broken := fakeServerCache(t, cacheDir, Config{PostgresVersion: "17.1.0", Name: "broken", TTL: time.Hour}, old)
+	require.NoError(t, os.Remove(filepath.Join(cacheDir, "server", broken.ID(), "data", "PG_VERSION")))
+
+	removed, err := GC(ctx, cacheDir, GCPolicy{})
+	require.ErrorIs(t, err, errServerNotFound)
+	require.Equal(t, []string{expired.ID()}, removed)
+	servers, err := ServersFromCache(cacheDir)
+	require.NoError(t, err)
+	ids := make([]string, 0, len(servers))
+	for _, server := range servers {
+		ids = append(ids, server.ID())
+	}
+	require.ElementsMatch(t, []string{fresh.ID(), broken.ID()}, ids)
+	// gc doesn't populate the incomplete entry
+	_, err = os.Stat(filepath.Join(cacheDir, "server", broken.ID(), "data", "PG_VERSION"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestGC_notInstalled(t *testing.T) {
+	ctx := context.Background()
+	cacheDir := t.TempDir()
+	repo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected download of %s", r.URL.Path)
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(repo.Close)
+	pgm := NewPGManager(PGMConfig{CacheDir: filepath.Join(cacheDir, "postgres"), MavenURLs: []string{repo.URL}})
+	// the postgres version of both servers has been pruned
+	old := time.Now().Add(-48 * time.Hour)
+	expired := fakeServerCache(t, cacheDir, Config{PostgresVersion: "17.1.0", Name: "expired", TTL: time.Hour}, old)
+	unused := fakeServerCache(t, cacheDir, Config{PostgresVersion: "17.1.0", Name: "unused"}, old)
+
+	removed, err := GC(ctx, cacheDir, GCPolicy{MaxUnused: 24 * time.Hour, PGManager: pgm})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{expired.ID(), unused.ID()}, removed)
+	servers, err := ServersFromCache(cacheDir)
+	require.NoError(t, err)
+	require.Empty(t, servers)
+}
+
+func TestServer_Remove(t *testing.T) {
+	ctx := context.Background()
+	cacheDir := t.TempDir()
+	fakePGInstall(t, cacheDir, "17.1.0")
+	srv := fakeServerCache(t, cacheDir, Config{PostgresVersion: "17.1.0"}, time.Now())
+	srv.existingOnly = true
+	require.NoError(t, srv.Remove(ctx))
+	_, err := srv.Status(ctx)
+	require.ErrorIs(t, err, errServerNotFound)
+	_, err = os.Stat(filepath.Join(cacheDir, "server", srv.ID()))
+	require.ErrorIs(t, err, os.ErrNotExist)
+	// removing a missing server is a no-op
+	require.NoError(t, srv.Remove(ctx))
+}
+
+func TestServer_CreatedAt(t *testing.T) {
+	ctx := context.Background()
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+	srv := fakeServerCache(t, t.TempDir(), Config{}, created)
+	got, err := srv.CreatedAt(ctx)
+	require.NoError(t, err)
+	require.True(t, created.Equal(got))
+	started, err := srv.LastStarted(ctx)
+	require.NoError(t, err)
+	require.True(t, started.IsZero())
+}
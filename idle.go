@@ -0,0 +1,235 @@
+package pgdevserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Environment variables used to run the current executable as an idle watcher.
+const (
+	idleWatcherIDEnv      = "PGDEVSERVER_IDLE_WATCHER_ID"
+	idleWatcherCacheEnv   = "PGDEVSERVER_IDLE_WATCHER_CACHE"
+	idleWatcherTimeoutEnv = "PGDEVSERVER_IDLE_WATCHER_TIMEOUT"
+
+	// The watcher's PGManager settings. Maven URLs are separated by spaces.
+	idleWatcherPGCacheEnv   = "PGDEVSERVER_IDLE_WATCHER_PG_CACHE"
+	idleWatcherMavenURLsEnv = "PGDEVSERVER_IDLE_WATCHER_MAVEN_URLS"
+	idleWatcherSystemPGEnv  = "PGDEVSERVER_IDLE_WATCHER_SYSTEM_PG"
+)
+
+var idleWatcherEnvs = []string{
+	idleWatcherIDEnv, idleWatcherCacheEnv, idleWatcherTimeoutEnv,
+	idleWatcherPGCacheEnv, idleWatcherMavenURLsEnv, idleWatcherSystemPGEnv,
+}
+
+// idleWatcherEnabled is set once RunIdleWatcher has been called. Start only spawns watchers from programs that call
+// it because the watcher is the current executable started again.
+var idleWatcherEnabled atomic.Bool
+
+// RunIdleWatcher runs the current process as an idle watcher and exits when it was started by Server.Start to enforce
+// Config.IdleTimeout. Otherwise it returns immediately. Programs that start servers with an IdleTimeout must call it
+// at the start of main.
+func RunIdleWatcher() {
+	idleWatcherEnabled.Store(true)
+	id := os.Getenv(idleWatcherIDEnv)
+	if id == "" {
+		return
+	}
+	cacheDir := os.Getenv(idleWatcherCacheEnv)
+	timeout, err := time.ParseDuration(os.Getenv(idleWatcherTimeoutEnv))
+	systemPG, systemPGErr := parseSystemPostgresMode(os.Getenv(idleWatcherSystemPGEnv))
+	err = errors.Join(err, systemPGErr)
+	// the watcher must find postgres the same way as the process that started the server
+	pgm := NewPGManager(PGMConfig{
+		CacheDir:       os.Getenv(idleWatcherPGCacheEnv),
+		MavenURLs:      strings.Fields(os.Getenv(idleWatcherMavenURLsEnv)),
+		SystemPostgres: systemPG,
+	})
+	// keep the variables away from anything the watcher runs
+	for _, env := range idleWatcherEnvs {
+		err = errors.Join(err, os.Unsetenv(env))
+	}
+	if err == nil {
+		err = runIdleWatcher(context.Background(), cacheDir, id, timeout, pgm)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// errIdleWatcherDisabled is returned when starting a server with an IdleTimeout in a program that doesn't call
+// RunIdleWatcher.
+var errIdleWatcherDisabled = errors.New("idle timeouts require calling pgdevserver.RunIdleWatcher in main")
+
+func idleWatcherPIDPath(cacheDir string) string {
+	return filepath.Join(cacheDir, "config", "idle_watcher.pid")
+}
+
+// ensureIdleWatcher starts an idle watcher for the server unless one is already running.
+func (s *Server) ensureIdleWatcher(cacheDir string) error {
+	if s.config.IdleTimeout <= 0 {
+		return nil
+	}
+	pidFile := idleWatcherPIDPath(cacheDir)
+	pid, err := readPIDFile(pidFile)
+	if err != nil {
+		return err
+	}
+	if pid != 0 && processRunning(pid) {
+		return nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	pgm := s.config.PGManager
+	pgm.init()
+	cmd := exec.Command(exe)
+	cmd.Env = append(os.Environ(),
+		idleWatcherIDEnv+"="+s.config.cacheKey(),
+		idleWatcherCacheEnv+"="+s.config.CacheDir,
+		idleWatcherTimeoutEnv+"="+s.config.IdleTimeout.String(),
+		idleWatcherPGCacheEnv+"="+pgm.config.CacheDir,
+		idleWatcherMavenURLsEnv+"="+strings.Join(pgm.config.MavenURLs, " "),
+		idleWatcherSystemPGEnv+"="+pgm.config.SystemPostgres.String(),
+	)
+	cmd.SysProcAttr = detachedProcAttr()
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("starting idle watcher: %w", err)
+	}
+	err = os.MkdirAll(filepath.Dir(pidFile), 0o700)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(pidFile, []byte(strconv.Itoa(cmd.Process.Pid)), 0o600)
+	if err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// runIdleWatcher polls the server until it stops or has had no client connections for timeout, in which case
+// it stops the server.
+func runIdleWatcher(ctx context.Context, rootCache, id string, timeout time.Duration, pgm *PGManager) (errOut error) {
+	cached, err := ServerFromCache(rootCache, id)
+	if err != nil {
+		return err
+	}
+	cfg := cached.Config()
+	cfg.PGManager = pgm
+	srv := New(cfg)
+	srv.existingOnly = true
+	defer func() { errOut = errors.Join(errOut, srv.removeIdleWatcherPID(ctx)) }()
+	interval := min(max(timeout/10, time.Second), time.Minute)
+	lastActive := time.Now()
+	for {
+		time.Sleep(interval)
+		status, err := srv.Status(ctx)
+		if errors.Is(err, errServerNotFound) {
+			// the server was removed
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if status != StatusRunning {
+			return nil
+		}
+		n, err := srv.clientConnections(ctx)
+		// Failing to count connections is treated as activity so that a hiccup never stops a busy server.
+		if err != nil || n > 0 {
+			lastActive = time.Now()
+			continue
+		}
+		if time.Since(lastActive) >= timeout {
+			return srv.Stop(ctx)
+		}
+	}
+}
+
+// clientConnections returns the number of client connections other than the one used to count them.
+func (s *Server) clientConnections(ctx context.Context) (_ int, errOut error) {
+	u, err := s.ConnectionURL(ctx)
+	if err != nil {
+		return 0, err
+	}
+	conn, err := pgx.Connect(ctx, u)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { errOut = errors.Join(errOut, conn.Close(ctx)) }()
+	var n int
+	err = conn.QueryRow(ctx, `
+		SELECT count(*) FROM pg_stat_activity
+		WHERE backend_type = 'client backend' AND pid <> pg_backend_pid()
+	`).Scan(&n)
+	return n, err
+}
+
+// removeIdleWatcherPID removes the pid file if it belongs to the current process.
+func (s *Server) removeIdleWatcherPID(ctx context.Context) error {
+	err := s.withCacheLock(ctx, func(cacheDir string) error {
+		pidFile := idleWatcherPIDPath(cacheDir)
+		pid, err := readPIDFile(pidFile)
+		if err != nil || pid != os.Getpid() {
+			return err
+		}
+		return os.Remove(pidFile)
+	})
+	if errors.Is(err, errServerNotFound) {
+		return nil
+	}
+	return err
+}
+
+// stopIdleWatcher kills the server's idle watcher if it is running.
+func stopIdleWatcher(cacheDir string) error {
+	pidFile := idleWatcherPIDPath(cacheDir)
+	pid, err := readPIDFile(pidFile)
+	if err != nil || pid == 0 || !processRunning(pid) {
+		return err
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	err = p.Kill()
+	if err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("stopping idle watcher: %w", err)
+	}
+	return os.Remove(pidFile)
+}
+
+// readPIDFile returns the pid in filename or 0 if the file does not exist.
+func readPIDFile(filename string) (int, error) {
+	b, err := os.ReadFile(filename)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return 0, nil
+	case err != nil:
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package pgdevserver
+
+import "syscall"
+
+func detachedProcAttr() *syscall.SysProcAttr {
+	return nil
+}
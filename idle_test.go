@@ -0,0 +1,107 @@
+package pgdevserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain lets TestServer_IdleTimeout spawn the test binary as its idle watcher.
+func TestMain(m *testing.M) {
+	RunIdleWatcher()
+	os.Exit(m.Run())
+}
+
+func TestServer_IdleTimeout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	ctx := context.Background()
+	srv := New(Config{
+		PostgresVersion: "17.1.0",
+		CacheDir:        filepath.Join(testCacheDir, "TestServer_IdleTimeout"),
+		IdleTimeout:     2 * time.Second,
+	})
+	require.NoError(t, srv.Start(ctx))
+	t.Cleanup(func() { require.NoError(t, srv.Stop(ctx)) })
+	require.Eventually(t, func() bool {
+		status, err := srv.Status(ctx)
+		return err == nil && status == StatusStopped
+	}, time.Minute, time.Second)
+}
+
+func Test_readPIDFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "pid")
+	pid, err := readPIDFile(filename)
+	require.NoError(t, err)
+	require.Zero(t, pid)
+	require.NoError(t, os.WriteFile(filename, []byte("123\n"), 0o600))
+	pid, err = readPIDFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, 123, pid)
+	require.True(t, processRunning(os.Getpid()))
+}
+
+func Test_runIdleWatcher(t *testing.T) {
+	ctx := context.Background()
+	cacheDir := t.TempDir()
+	// postgres is only installed in the watcher's PGManager cache, so a default PGManager would download it
+	pgRoot := t.TempDir()
+	fakePGInstall(t, pgRoot, "17.1.0")
+	pgm := NewPGManager(PGMConfig{CacheDir: filepath.Join(pgRoot, "postgres"), SystemPostgres: SystemPostgresPrefer})
+	srv := fakeServerCache(t, cacheDir, Config{PostgresVersion: "17.1.0", IdleTimeout: time.Hour}, time.Now())
+	// the fake pg_ctl reports a stopped server, so the watcher exits after its first check
+	require.NoError(t, runIdleWatcher(ctx, cacheDir, srv.ID(), 10*time.Second, pgm))
+}
+
+func Test_parseSystemPostgresMode(t *testing.T) {
+	for _, mode := range []SystemPostgresMode{SystemPostgresNever, SystemPostgresPrefer, SystemPostgresOnly} {
+		got, err := parseSystemPostgresMode(mode.String())
+		require.NoError(t, err)
+		require.Equal(t, mode, got)
+	}
+	got, err := parseSystemPostgresMode("")
+	require.NoError(t, err)
+	require.Equal(t, SystemPostgresNever, got)
+	_, err = parseSystemPostgresMode("sometimes")
+	require.Error(t, err)
+}
+
+func TestServer_Restart_idleWatcher(t *testing.T) {
+	ctx := context.Background()
+	cacheDir := t.TempDir()
+	fakePGInstall(t, cacheDir, "17.1.0")
+	srv := fakeServerCache(t, cacheDir, Config{PostgresVersion: "17.1.0", IdleTimeout: time.Hour}, time.Now())
+	dir := filepath.Join(cacheDir, "server", srv.ID())
+	t.Cleanup(func() { require.NoError(t, stopIdleWatcher(dir)) })
+	watcherPID := func() int {
+		t.Helper()
+		pid, err := readPIDFile(idleWatcherPIDPath(dir))
+		require.NoError(t, err)
+		require.NotZero(t, pid)
+		require.True(t, processRunning(pid))
+		return pid
+	}
+
+	require.NoError(t, srv.Start(ctx))
+	pid := watcherPID()
+
+	// each restart replaces the watcher instead of finding the old one about to exit
+	require.NoError(t, srv.Restart(ctx))
+	restartPID := watcherPID()
+	require.NotEqual(t, pid, restartPID)
+
+	require.NoError(t, srv.Snapshot(ctx, "snap"))
+	snapshotPID := watcherPID()
+	require.NotEqual(t, restartPID, snapshotPID)
+
+	require.NoError(t, srv.Restore(ctx, "snap"))
+	require.NotEqual(t, snapshotPID, watcherPID())
+	status, err := srv.Status(ctx)
+	require.NoError(t, err)
+	require.Equal(t, StatusRunning, status)
+}
@@ -0,0 +1,10 @@
+//go:build unix
+
+package pgdevserver
+
+import "syscall"
+
+// detachedProcAttr starts the process in its own session so it outlives the terminal that started it.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
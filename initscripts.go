@@ -0,0 +1,213 @@
+package pgdevserver
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/willabides/pgdevserver/internal"
+)
+
+func initScriptsPendingPath(cacheDir string) string {
+	return filepath.Join(cacheDir, "config", "init_scripts_pending")
+}
+
+// markInitScriptsPending records that a newly created server has not run its init scripts yet.
+func markInitScriptsPending(cacheDir string) error {
+	filename := initScriptsPendingPath(cacheDir)
+	err := os.MkdirAll(filepath.Dir(filename), 0o700)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, nil, 0o600)
+}
+
+// clearInitScriptsPending records that the server's init scripts are done.
+func clearInitScriptsPending(cacheDir string) error {
+	err := os.Remove(initScriptsPendingPath(cacheDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// initScripts returns the server's init scripts or nil if it has none.
+func (s *Server) initScripts() fs.FS {
+	switch {
+	case s.config.InitScripts != nil:
+		return s.config.InitScripts
+	case s.config.InitScriptsDir != "":
+		return os.DirFS(s.config.InitScriptsDir)
+	default:
+		return nil
+	}
+}
+
+// runInitScripts runs the server's init scripts the first time a server that was created with init scripts is
+// started. Each script that succeeds is recorded in the pending file, so a start after a failure resumes with the
+// script that failed instead of running the earlier ones again. The pending file is removed once every script has run.
+func (s *Server) runInitScripts(ctx context.Context, cacheDir string) error {
+	pending := initScriptsPendingPath(cacheDir)
+	b, err := os.ReadFile(pending)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	}
+	scripts := s.initScripts()
+	if scripts == nil {
+		// The scripts are unknown, as they are for a server loaded from the cache that was created with an fs.FS.
+		// They are left for a start that has them.
+		return nil
+	}
+	var done []string
+	for _, name := range strings.Split(string(b), "\n") {
+		if name != "" {
+			done = append(done, name)
+		}
+	}
+	err = s.execInitScripts(ctx, cacheDir, scripts, done)
+	if err != nil {
+		return err
+	}
+	return os.Remove(pending)
+}
+
+// execInitScripts runs the scripts in scripts that aren't in done, appending the name of each one that succeeds to
+// the pending file.
+func (s *Server) execInitScripts(ctx context.Context, cacheDir string, scripts fs.FS, done []string) error {
+	entries, err := fs.ReadDir(scripts, ".")
+	if err != nil {
+		return fmt.Errorf("reading init scripts: %w", err)
+	}
+	params, err := s.cacheDirConnParams(cacheDir, "")
+	if err != nil {
+		return err
+	}
+	// fs.ReadDir sorts by filename, which gives the same lexical order as the postgres docker image.
+	for _, entry := range entries {
+		name := entry.Name()
+		if slices.Contains(done, name) {
+			continue
+		}
+		switch {
+		case entry.IsDir():
+			continue
+		case strings.HasSuffix(name, ".sql"), strings.HasSuffix(name, ".sql.gz"):
+			err = s.execSQLScript(ctx, params, scripts, name)
+		case strings.HasSuffix(name, ".sh"):
+			err = s.execShellScript(ctx, cacheDir, params, scripts, name)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("running init script %s: %w", name, err)
+		}
+		err = appendLine(initScriptsPendingPath(cacheDir), name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendLine appends line and a newline to filename.
+func appendLine(filename, line string) (errOut error) {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer func() { errOut = errors.Join(errOut, f.Close()) }()
+	_, err = f.WriteString(line + "\n")
+	return err
+}
+
+// execSQLScript runs a SQL script one statement at a time and stops at the first error, like psql with
+// ON_ERROR_STOP. Statements outside an explicit transaction are committed on their own, so statements like
+// CREATE DATABASE that can't run in a transaction block work. Statements before a failure are not rolled back.
+func (s *Server) execSQLScript(ctx context.Context, params connParams, scripts fs.FS, name string) (errOut error) {
+	f, err := scripts.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func() { errOut = errors.Join(errOut, f.Close()) }()
+	var r io.Reader = f
+	if strings.HasSuffix(name, ".gz") {
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer func() { errOut = errors.Join(errOut, gz.Close()) }()
+		r = gz
+	}
+	script, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	conn, err := pgx.Connect(ctx, params.url())
+	if err != nil {
+		return err
+	}
+	defer func() { errOut = errors.Join(errOut, conn.Close(ctx)) }()
+	statements, rest := internal.SplitSQL(string(script))
+	if rest != "" {
+		// like psql, run a final statement without a semicolon
+		statements = append(statements, rest)
+	}
+	for _, stmt := range statements {
+		// Exec without arguments uses the simple protocol, so each statement runs in its own implicit transaction.
+		_, err = conn.Exec(ctx, stmt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execShellScript runs a script with sh. The script is copied to a temp file because scripts may not be backed by
+// the os filesystem.
+func (s *Server) execShellScript(
+	ctx context.Context,
+	cacheDir string,
+	params connParams,
+	scripts fs.FS,
+	name string,
+) (errOut error) {
+	script, err := fs.ReadFile(scripts, name)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp("", "pgdevserver-init-*.sh")
+	if err != nil {
+		return err
+	}
+	defer func() { errOut = errors.Join(errOut, os.Remove(tmp.Name())) }()
+	_, err = tmp.Write(script)
+	err = errors.Join(err, tmp.Close())
+	if err != nil {
+		return err
+	}
+	binDir, unlock, err := s.bin(ctx, cacheDir)
+	if err != nil {
+		return err
+	}
+	defer func() { errOut = errors.Join(errOut, unlock()) }()
+	cmd := exec.CommandContext(ctx, "sh", tmp.Name())
+	cmd.Env = os.Environ()
+	for k, v := range params.env() {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Env = append(cmd.Env, "PATH="+binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return execRun(cmd)
+}
@@ -0,0 +1,132 @@
+package pgdevserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_InitScripts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	ctx := context.Background()
+	var gz bytes.Buffer
+	gzw := gzip.NewWriter(&gz)
+	_, err := gzw.Write([]byte("INSERT INTO foo VALUES (2);"))
+	require.NoError(t, err)
+	require.NoError(t, gzw.Close())
+	marker := filepath.Join(t.TempDir(), "marker")
+	scripts := fstest.MapFS{
+		"01-schema.sql":  {Data: []byte("CREATE TABLE foo (id int); INSERT INTO foo VALUES (1);")},
+		"02-data.sql.gz": {Data: gz.Bytes()},
+		"03-env.sh":      {Data: []byte(`echo "$PGPORT" > "` + marker + `"`)},
+		// statements that can't run in a transaction block work next to others, like in the docker image
+		"04-database.sql": {Data: []byte("CREATE DATABASE app;\nALTER SYSTEM SET work_mem = '8MB';\nSELECT 1")},
+		"README.md":       {Data: []byte("ignored")},
+	}
+	srv := New(Config{
+		PostgresVersion: "17.1.0",
+		CacheDir:        t.TempDir(),
+		InitScripts:     scripts,
+	})
+	require.NoError(t, srv.Start(ctx))
+	t.Cleanup(func() { require.NoError(t, srv.Stop(ctx)) })
+
+	u, err := srv.ConnectionURL(ctx)
+	require.NoError(t, err)
+	conn, err := pgx.Connect(ctx, u)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, conn.Close(ctx)) })
+	var count int
+	require.NoError(t, conn.QueryRow(ctx, "SELECT count(*) FROM foo").Scan(&count))
+	require.Equal(t, 2, count)
+	got, err := os.ReadFile(marker)
+	require.NoError(t, err)
+	require.NotEmpty(t, bytes.TrimSpace(got))
+	var exists bool
+	require.NoError(t, conn.QueryRow(ctx, "SELECT exists (SELECT FROM pg_database WHERE datname = 'app')").Scan(&exists))
+	require.True(t, exists)
+
+	// scripts only run once
+	require.NoError(t, srv.Stop(ctx))
+	require.NoError(t, srv.Start(ctx))
+	require.NoError(t, conn.Close(ctx))
+	conn, err = pgx.Connect(ctx, u)
+	require.NoError(t, err)
+	require.NoError(t, conn.QueryRow(ctx, "SELECT count(*) FROM foo").Scan(&count))
+	require.Equal(t, 2, count)
+}
+
+func TestServer_InitScripts_failure(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	ctx := context.Background()
+	scripts := fstest.MapFS{
+		"01-schema.sql": {Data: []byte("CREATE TABLE foo (id int);")},
+		"02-data.sql":   {Data: []byte("SELECT 1/0; INSERT INTO foo VALUES (1);")},
+	}
+	srv := New(Config{
+		PostgresVersion: "17.1.0",
+		CacheDir:        t.TempDir(),
+		InitScripts:     scripts,
+	})
+	require.ErrorContains(t, srv.Start(ctx), "running init script 02-data.sql")
+	t.Cleanup(func() { require.NoError(t, srv.Stop(ctx)) })
+
+	// the next start resumes with the script that failed
+	scripts["02-data.sql"] = &fstest.MapFile{Data: []byte("INSERT INTO foo VALUES (1);")}
+	require.NoError(t, srv.Start(ctx))
+	u, err := srv.ConnectionURL(ctx)
+	require.NoError(t, err)
+	conn, err := pgx.Connect(ctx, u)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, conn.Close(ctx)) })
+	var count int
+	require.NoError(t, conn.QueryRow(ctx, "SELECT count(*) FROM foo").Scan(&count))
+	require.Equal(t, 1, count)
+}
+
+func TestServer_runInitScripts(t *testing.T) {
+	ctx := context.Background()
+	cacheDir := t.TempDir()
+	fakePGInstall(t, cacheDir, "17.1.0")
+	out := filepath.Join(t.TempDir(), "out")
+	scripts := fstest.MapFS{
+		"01-first.sh":  {Data: []byte(`echo first >> "` + out + `"`)},
+		"02-second.sh": {Data: []byte(`echo second >> "` + out + `"; exit 1`)},
+	}
+	srv := fakeServerCache(t, cacheDir, Config{PostgresVersion: "17.1.0"}, time.Now())
+	dir := filepath.Join(cacheDir, "server", srv.ID())
+	require.NoError(t, markInitScriptsPending(dir))
+
+	// unknown scripts are left pending
+	require.NoError(t, srv.runInitScripts(ctx, dir))
+	_, err := os.Stat(initScriptsPendingPath(dir))
+	require.NoError(t, err)
+
+	srv.config.InitScripts = scripts
+	require.Error(t, srv.runInitScripts(ctx, dir))
+	scripts["02-second.sh"] = &fstest.MapFile{Data: []byte(`echo second >> "` + out + `"`)}
+	require.NoError(t, srv.runInitScripts(ctx, dir))
+	got, err := os.ReadFile(out)
+	require.NoError(t, err)
+	require.Equal(t, "first\nsecond\nsecond\n", string(got))
+	_, err = os.Stat(initScriptsPendingPath(dir))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	// later starts are no-ops
+	require.NoError(t, srv.runInitScripts(ctx, dir))
+	got, err = os.ReadFile(out)
+	require.NoError(t, err)
+	require.Equal(t, "first\nsecond\nsecond\n", string(got))
+}
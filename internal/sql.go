@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"regexp"
+	"strings"
+)
+
+// dollarQuotePattern matches the opening tag of a dollar-quoted string like $$ or $body$.
+var dollarQuotePattern = regexp.MustCompile(`^\$([A-Za-z_\x80-\xff][A-Za-z0-9_\x80-\xff]*)?\$`)
+
+// SplitSQL splits sql at semicolons that aren't in a string, a quoted identifier, a dollar-quoted string or a
+// comment. Each statement includes its semicolon. rest is the text after the last statement, or "" if that is only
+// whitespace and comments.
+func SplitSQL(sql string) (statements []string, rest string) {
+	var (
+		quote        byte   // the quote character while in a string or quoted identifier
+		escapeString bool   // whether the current string is an E'' string, where backslash escapes a quote
+		dollarTag    string // the tag while in a dollar-quoted string
+		commentDepth int    // the nesting depth of /* */ comments
+		start        int    // the start of the current statement
+		content      bool   // whether the current statement has anything but whitespace and comments
+	)
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		tail := sql[i:]
+		switch {
+		case dollarTag != "":
+			if strings.HasPrefix(tail, dollarTag) {
+				i += len(dollarTag) - 1
+				dollarTag = ""
+			}
+		case quote != 0:
+			switch {
+			case escapeString && c == '\\':
+				i++
+			case c == quote:
+				// a doubled quote closes the string and immediately opens it again
+				quote = 0
+			}
+		case commentDepth > 0:
+			switch {
+			case strings.HasPrefix(tail, "*/"):
+				commentDepth--
+				i++
+			case strings.HasPrefix(tail, "/*"):
+				commentDepth++
+				i++
+			}
+		case strings.HasPrefix(tail, "--"):
+			end := strings.IndexByte(tail, '\n')
+			if end == -1 {
+				end = len(tail) - 1
+			}
+			i += end
+		case strings.HasPrefix(tail, "/*"):
+			commentDepth++
+			i++
+		case c == ';':
+			statements = append(statements, sql[start:i+1])
+			start = i + 1
+			content = false
+		case c == ' ', c == '\t', c == '\n', c == '\r':
+		default:
+			content = true
+			switch {
+			case c == '\'' || c == '"':
+				quote = c
+				escapeString = c == '\'' && i > 0 && (sql[i-1] == 'E' || sql[i-1] == 'e') &&
+					(i == 1 || !isIdentByte(sql[i-2]))
+			case c == '$' && (i == 0 || !isIdentByte(sql[i-1])):
+				tag := dollarQuotePattern.FindString(tail)
+				if tag != "" {
+					dollarTag = tag
+					i += len(tag) - 1
+				}
+			}
+		}
+	}
+	if content || quote != 0 || dollarTag != "" || commentDepth > 0 {
+		return statements, sql[start:]
+	}
+	return statements, ""
+}
+
+// isIdentByte reports whether c can be part of an unquoted identifier.
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
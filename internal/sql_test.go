@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitSQL(t *testing.T) {
+	for _, tc := range []struct {
+		sql        string
+		statements []string
+		rest       string
+	}{
+		{sql: ""},
+		{sql: "  -- nothing\n/* here */\n"},
+		{sql: "SELECT 1", rest: "SELECT 1"},
+		{
+			sql:        "CREATE DATABASE app;\nVACUUM;\n",
+			statements: []string{"CREATE DATABASE app;", "\nVACUUM;"},
+		},
+		{
+			sql:        "SELECT ';'; SELECT 2 -- done;\n",
+			statements: []string{"SELECT ';';"},
+			rest:       " SELECT 2 -- done;\n",
+		},
+		{
+			sql:        "SELECT 1; -- trailing comment",
+			statements: []string{"SELECT 1;"},
+		},
+		{
+			sql:        "DO $$ BEGIN PERFORM 1; END $$;\nSELECT 'it''s; fine';",
+			statements: []string{"DO $$ BEGIN PERFORM 1; END $$;", "\nSELECT 'it''s; fine';"},
+		},
+		{
+			sql:        "SELECT 1; SELECT 'open;",
+			statements: []string{"SELECT 1;"},
+			rest:       " SELECT 'open;",
+		},
+		{
+			sql:        "SELECT 1; /* open",
+			statements: []string{"SELECT 1;"},
+			rest:       " /* open",
+		},
+	} {
+		statements, rest := SplitSQL(tc.sql)
+		require.Equal(t, tc.statements, statements, tc.sql)
+		require.Equal(t, tc.rest, rest, tc.sql)
+	}
+}
@@ -1,10 +1,13 @@
 package pgdevserver
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"net"
+	"net/url"
 	"os/exec"
+	"path/filepath"
 	"strings"
 )
 
@@ -36,6 +39,64 @@ func availableTcpPort(preferred string) (_ string, errOut error) {
 	return str[strings.LastIndex(str, ":")+1:], nil
 }
 
+// connParams describes how to connect to a server.
+type connParams struct {
+	// host is a hostname or, for unix domain sockets, the socket directory.
+	host     string
+	port     string
+	user     string
+	password string
+	dbname   string
+	// sslRootCert is the path to a CA certificate. When set, the URL requires a verified TLS connection.
+	sslRootCert string
+}
+
+// url builds a connection URL. An empty dbname connects to the user's default database.
+func (p connParams) url() string {
+	u := url.URL{
+		Scheme: "postgresql",
+		User:   url.User(p.user),
+	}
+	if p.password != "" {
+		u.User = url.UserPassword(p.user, p.password)
+	}
+	if p.dbname != "" {
+		u.Path = "/" + p.dbname
+	}
+	query := url.Values{}
+	if filepath.IsAbs(p.host) {
+		query.Set("host", p.host)
+		query.Set("port", p.port)
+	} else {
+		u.Host = p.host + ":" + p.port
+	}
+	if p.sslRootCert != "" {
+		query.Set("sslmode", "verify-full")
+		query.Set("sslrootcert", p.sslRootCert)
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// env returns libpq environment variables and DATABASE_URL for connecting with these parameters.
+func (p connParams) env() map[string]string {
+	env := map[string]string{
+		"DATABASE_URL": p.url(),
+		"PGHOST":       p.host,
+		"PGPORT":       p.port,
+		"PGUSER":       p.user,
+		"PGDATABASE":   cmp.Or(p.dbname, p.user),
+	}
+	if p.password != "" {
+		env["PGPASSWORD"] = p.password
+	}
+	if p.sslRootCert != "" {
+		env["PGSSLMODE"] = "verify-full"
+		env["PGSSLROOTCERT"] = p.sslRootCert
+	}
+	return env
+}
+
 // execRun is cmd.Run except the ExitError is populated with both stdout and stderr
 func execRun(cmd *exec.Cmd) error {
 	var exitErr *exec.ExitError
@@ -0,0 +1,57 @@
+// Package pgdevservertest provides helpers for using pgdevserver in tests.
+package pgdevservertest
+
+import (
+	"context"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/willabides/pgdevserver"
+)
+
+// maxNameLen keeps generated database names well under postgres' 63 byte identifier limit.
+const maxNameLen = 40
+
+// New starts the server described by cfg, or reuses it if it is already running, and creates a fresh database
+// for the test. It returns a connection URL for the new database. The database is dropped when the test
+// finishes. The server is left running so that it can be reused by other tests.
+func New(t testing.TB, cfg pgdevserver.Config) string {
+	t.Helper()
+	ctx := context.Background()
+	srv := pgdevserver.New(cfg)
+	err := srv.Start(ctx)
+	if err != nil {
+		t.Fatalf("starting server: %v", err)
+	}
+	name := databaseName(t.Name())
+	u, err := srv.CreateDatabase(ctx, name, nil)
+	if err != nil {
+		t.Fatalf("creating database: %v", err)
+	}
+	t.Cleanup(func() {
+		dropErr := srv.DropDatabase(context.Background(), name)
+		if dropErr != nil {
+			t.Errorf("dropping database %s: %v", name, dropErr)
+		}
+	})
+	return u
+}
+
+// databaseName returns a unique database name derived from a test name.
+func databaseName(testName string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return '_'
+		}
+	}, testName)
+	if len(name) > maxNameLen {
+		name = name[:maxNameLen]
+	}
+	return "test_" + name + "_" + strings.ToLower(rand.Text()[:8])
+}
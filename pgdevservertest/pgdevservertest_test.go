@@ -0,0 +1,37 @@
+package pgdevservertest
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"github.com/willabides/pgdevserver"
+)
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+	cfg := pgdevserver.Config{
+		PostgresVersion: "17.1.0",
+		CacheDir:        filepath.Join("..", "tmp", "test-cache", "TestNew"),
+	}
+	var dbNames []string
+	for range 2 {
+		u := New(t, cfg)
+		conn, err := pgx.Connect(ctx, u)
+		require.NoError(t, err)
+		var dbName string
+		require.NoError(t, conn.QueryRow(ctx, "SELECT current_database()").Scan(&dbName))
+		require.NoError(t, conn.Close(ctx))
+		require.NotContains(t, dbNames, dbName)
+		dbNames = append(dbNames, dbName)
+	}
+}
+
+func Test_databaseName(t *testing.T) {
+	name := databaseName("TestFoo/sub test")
+	require.Regexp(t, `^test_testfoo_sub_test_[a-z2-7]{8}$`, name)
+	name = databaseName(string(make([]byte, 100)))
+	require.LessOrEqual(t, len(name), 63)
+}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"net"
 	"net/http"
 	"os"
 	"path"
@@ -52,20 +53,53 @@ func versionFile(cacheDir string) string {
 }
 
 type PGMConfig struct {
-	// MavenURL is the base URL for maven repositories. Default is https://repo1.maven.org/maven2.
-	MavenURL string
+	// MavenURLs are base URLs of maven repositories to download from. They are tried in order, falling back to the
+	// next on network errors and 404 responses. Default is https://repo1.maven.org/maven2.
+	MavenURLs []string
 
 	// CacheDir is the directory containing the cache. Default is pgm under the xdg cache directory
 	CacheDir string
 
 	// HTTPClient is the http client to use for downloading files.
 	HTTPClient *http.Client
+
+	// Progress is called as postgres is downloaded and extracted during an install.
+	Progress func(Progress)
+
+	// SystemPostgres controls whether postgres installations found on the system are used. They are found with
+	// pg_config on PATH, in /usr/lib/postgresql/*/bin and in the directories listed in PGDEVSERVER_PG_BIN.
+	// Default is SystemPostgresNever.
+	SystemPostgres SystemPostgresMode
+}
+
+// ProgressPhase is a phase of installing postgres.
+type ProgressPhase string
+
+const (
+	ProgressDownload ProgressPhase = "download"
+	ProgressExtract  ProgressPhase = "extract"
+)
+
+// Progress reports how far along a phase of installing postgres is.
+type Progress struct {
+	Version string
+	Phase   ProgressPhase
+	// Done is the number of bytes processed so far.
+	Done int64
+	// Total is the number of bytes in the phase or -1 if it is unknown.
+	Total int64
 }
 
 type PGManager struct {
 	config PGMConfig
 	cache  bdcache.Cache
 
+	// retryBackoff is the wait before the first retry of a transient failure. Tests shorten it.
+	retryBackoff time.Duration
+
+	systemOnce sync.Once
+	systemBins map[string]string
+
 	initOnce sync.Once
 }
 
@@ -75,9 +109,18 @@ func NewPGManager(cfg PGMConfig) *PGManager {
 
 func (m *PGManager) init() {
 	m.initOnce.Do(func() {
-		m.config.MavenURL = cmp.Or(m.config.MavenURL, defaultMavenURL)
+		m.config.MavenURLs = slices.Clone(m.config.MavenURLs)
+		if len(m.config.MavenURLs) == 0 {
+			m.config.MavenURLs = []string{defaultMavenURL}
+		}
+		for i, u := range m.config.MavenURLs {
+			m.config.MavenURLs[i] = strings.TrimSuffix(u, "/")
+		}
 		m.config.CacheDir = cmp.Or(m.config.CacheDir, filepath.Join(xdg.CacheHome, "pgm"))
 		m.cache = bdcache.Cache{Root: m.config.CacheDir}
+		if m.retryBackoff == 0 {
+			m.retryBackoff = defaultRetryBackoff
+		}
 		if m.config.HTTPClient == nil {
 			m.config.HTTPClient = &http.Client{Timeout: time.Minute}
 		}
@@ -87,8 +130,21 @@ func (m *PGManager) init() {
 // AvailableVersions returns a list of available versions of postgres
 func (m *PGManager) AvailableVersions(ctx context.Context) ([]string, error) {
 	m.init()
+	var versions []string
+	err := m.withMavenFallback(ctx, func(mavenURL string) error {
+		var err error
+		versions, err = m.systemAvailableVersions(ctx, mavenURL)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (m *PGManager) systemAvailableVersions(ctx context.Context, mavenURL string) ([]string, error) {
 	system := runtime.GOOS + "/" + runtime.GOARCH
-	versions, err := m.availableVersions(ctx, m.config.MavenURL, system)
+	versions, err := m.availableVersions(ctx, mavenURL, system)
 	if err != nil {
 		return nil, err
 	}
@@ -97,7 +153,7 @@ func (m *PGManager) AvailableVersions(ctx context.Context) ([]string, error) {
 	if system != "darwin/arm64" {
 		return versions, nil
 	}
-	extraVersions, err := m.availableVersions(ctx, m.config.MavenURL, "darwin/amd64")
+	extraVersions, err := m.availableVersions(ctx, mavenURL, "darwin/amd64")
 	if err != nil {
 		return nil, err
 	}
@@ -125,9 +181,23 @@ func (m *PGManager) InstalledVersions() ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	systemVersions := slices.DeleteFunc(m.systemVersions(), func(v string) bool {
+		return slices.Contains(versions, v)
+	})
+	if len(systemVersions) == 0 {
+		return versions, nil
+	}
+	versions = append(versions, systemVersions...)
+	internal.SortVersions(versions)
 	return versions, nil
 }
 
+// errSystemOnly returns the error for a version that isn't installed on the system when SystemPostgresOnly forbids
+// downloading it.
+func errSystemOnly(version string) error {
+	return fmt.Errorf("postgres %s is not installed on the system and downloads are disabled", version)
+}
+
 func (m *PGManager) Remove(version string) error {
 	m.init()
 	return m.cache.Evict(pgCacheKey(version))
@@ -140,14 +210,25 @@ func (m *PGManager) rlockVersion(ctx context.Context, version string) (dir strin
 		return "", nil, fmt.Errorf("invalid version: %w", err)
 	}
 	populator := func(cacheDir string) error {
-		return m.pgmPopulateCache(ctx, cacheDir, m.config.MavenURL, version)
+		return m.pgmPopulateCache(ctx, cacheDir, version)
 	}
 	return m.cache.Dir(pgCacheKey(version), pgmValidateCache, populator)
 }
 
-// Install assures that the given version of postgres is installed
+// Install assures that the given version of postgres is installed. The version may be a constraint accepted by
+// ResolveVersion.
 func (m *PGManager) Install(ctx context.Context, version string) error {
 	m.init()
+	version, err := m.ResolveVersion(ctx, version)
+	if err != nil {
+		return err
+	}
+	if m.systemBin(version) != "" {
+		return nil
+	}
+	if m.config.SystemPostgres == SystemPostgresOnly {
+		return errSystemOnly(version)
+	}
 	_, unlock, err := m.rlockVersion(ctx, version)
 	if err != nil {
 		return err
@@ -160,6 +241,13 @@ func (m *PGManager) Install(ctx context.Context, version string) error {
 // Use this to run pg_ctl, initdb, etc.
 func (m *PGManager) Bin(ctx context.Context, version string) (binDir string, unlock func() error, _ error) {
 	m.init()
+	systemBin := m.systemBin(version)
+	if systemBin != "" {
+		return systemBin, func() error { return nil }, nil
+	}
+	if m.config.SystemPostgres == SystemPostgresOnly {
+		return "", nil, errSystemOnly(version)
+	}
 	cacheDir, unlock, err := m.rlockVersion(ctx, version)
 	if err != nil {
 		return "", nil, err
@@ -167,23 +255,120 @@ func (m *PGManager) Bin(ctx context.Context, version string) (binDir string, unl
 	return filepath.Join(cacheDir, "bin"), unlock, nil
 }
 
+// dataBin is Bin for running an existing data directory created by version. System packages replace minor versions
+// on upgrade, so when version is neither on the system nor downloaded, a system installation with the same major
+// version, which can run the same data directory, is used instead.
+func (m *PGManager) dataBin(ctx context.Context, version string) (binDir string, unlock func() error, _ error) {
+	m.init()
+	if m.systemBin(version) == "" && pgmValidateCache(filepath.Join(m.config.CacheDir, pgCacheKey(version))) != nil {
+		systemBin := m.systemMajorBin(version)
+		if systemBin != "" {
+			return systemBin, func() error { return nil }, nil
+		}
+	}
+	return m.Bin(ctx, version)
+}
+
+// errNotInstalled is returned by installedBin when no installation can run a version.
+var errNotInstalled = errors.New("postgres is not installed")
+
+// installedBin is dataBin without downloading. It returns errNotInstalled when neither version nor a system
+// installation with the same major version is available.
+func (m *PGManager) installedBin(version string) (binDir string, unlock func() error, _ error) {
+	m.init()
+	systemBin := m.systemBin(version)
+	if systemBin != "" {
+		return systemBin, func() error { return nil }, nil
+	}
+	if m.config.SystemPostgres != SystemPostgresOnly &&
+		pgmValidateCache(filepath.Join(m.config.CacheDir, pgCacheKey(version))) == nil {
+		cacheDir, unlock, err := m.cache.Dir(pgCacheKey(version), pgmValidateCache, nil)
+		if err != nil {
+			return "", nil, err
+		}
+		return filepath.Join(cacheDir, "bin"), unlock, nil
+	}
+	systemBin = m.systemMajorBin(version)
+	if systemBin != "" {
+		return systemBin, func() error { return nil }, nil
+	}
+	return "", nil, fmt.Errorf("%w: %s", errNotInstalled, version)
+}
+
 func pgmValidateCache(cacheDir string) error {
 	_, err := os.Stat(filepath.Join(cacheDir, "bin", "pg_ctl"))
 	return err
 }
 
-func (m *PGManager) pgmPopulateCache(ctx context.Context, cacheDir, mavenURL, version string) error {
-	jarBytes, err := m.download(ctx, mavenURL, version)
+func (m *PGManager) pgmPopulateCache(ctx context.Context, cacheDir, version string) (errOut error) {
+	// An incomplete download is kept so that the next attempt can resume it.
+	jarFile := m.downloadPath(version)
+	defer func() {
+		if errOut != nil {
+			return
+		}
+		err := os.Remove(jarFile)
+		if !errors.Is(err, os.ErrNotExist) {
+			errOut = err
+		}
+	}()
+	var digest string
+	err := m.withMavenFallback(ctx, func(mavenURL string) error {
+		var err error
+		digest, err = m.download(ctx, mavenURL, version, jarFile)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	err = m.extractJar(ctx, cacheDir, jarFile, version)
 	if err != nil {
 		return err
 	}
-	err = extractJar(ctx, cacheDir, jarBytes)
+	err = os.WriteFile(checksumFile(cacheDir), []byte(digest+"\n"), 0o600)
 	if err != nil {
 		return err
 	}
 	return os.WriteFile(versionFile(cacheDir), []byte(version+"\n"), 0o600)
 }
 
+// errMavenNotFound is returned when a maven repository responds with 404.
+var errMavenNotFound = errors.New("not found")
+
+// checkMavenResponse returns an error unless resp is a 200. A 404 wraps errMavenNotFound.
+func checkMavenResponse(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", errMavenNotFound, resp.Request.URL)
+	default:
+		return &httpStatusError{StatusCode: resp.StatusCode}
+	}
+}
+
+// withMavenFallback calls fn with each maven repository in order until it succeeds. It moves on to the next
+// repository when fn fails with a network error or a 404 and stops at any other error. Transient failures are
+// retried by starting over with the first repository once every repository has been tried, so an unreachable
+// repository doesn't hold up the ones after it.
+func (m *PGManager) withMavenFallback(ctx context.Context, fn func(mavenURL string) error) error {
+	return m.retry(ctx, func() error {
+		var errs []error
+		for _, mavenURL := range m.config.MavenURLs {
+			err := fn(mavenURL)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", mavenURL, err))
+			var netErr net.Error
+			if ctx.Err() != nil || !(errors.Is(err, errMavenNotFound) || errors.As(err, &netErr)) {
+				break
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
 // availableMavenVersions queries maven metadata for available versions of a maven artifact.
 func (m *PGManager) availableMavenVersions(
 	ctx context.Context,
@@ -199,8 +384,9 @@ func (m *PGManager) availableMavenVersions(
 		return nil, err
 	}
 	defer func() { errOut = errors.Join(errOut, resp.Body.Close()) }()
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected http status code %d", resp.StatusCode)
+	err = checkMavenResponse(resp)
+	if err != nil {
+		return nil, err
 	}
 	var metadata struct {
 		Versioning struct {
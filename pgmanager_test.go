@@ -1,16 +1,28 @@
 package pgdevserver
 
 import (
+	"archive/zip"
+	"bytes"
 	"cmp"
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/mholt/archives"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/willabides/pgdevserver/internal"
 )
 
 func testMgr(t testing.TB, cacheDir string) *PGManager {
@@ -29,6 +41,50 @@ func TestManager_AvailableVersions(t *testing.T) {
 	require.NotEmpty(t, versions)
 }
 
+func TestManager_AvailableVersions_fallback(t *testing.T) {
+	metadata := `<metadata><versioning><versions>
+		<version>16.4.0</version><version>17.2.0</version>
+	</versions></versioning></metadata>`
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/maven-metadata.xml") {
+			http.NotFound(w, r)
+			return
+		}
+		_, err := w.Write([]byte(metadata))
+		assert.NoError(t, err)
+	}))
+	t.Cleanup(mirror.Close)
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(notFound.Close)
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(broken.Close)
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	t.Run("falls back on 404 and network errors", func(t *testing.T) {
+		mgr := NewPGManager(PGMConfig{
+			CacheDir:  t.TempDir(),
+			MavenURLs: []string{unreachable.URL, notFound.URL + "/", mirror.URL},
+		})
+		mgr.retryBackoff = time.Millisecond
+		versions, err := mgr.AvailableVersions(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, []string{"16.4.0", "17.2.0"}, versions)
+	})
+
+	t.Run("stops on other errors", func(t *testing.T) {
+		mgr := NewPGManager(PGMConfig{
+			CacheDir:  t.TempDir(),
+			MavenURLs: []string{broken.URL, mirror.URL},
+		})
+		mgr.retryBackoff = time.Millisecond
+		_, err := mgr.AvailableVersions(t.Context())
+		require.ErrorContains(t, err, "unexpected http status code 500")
+	})
+}
+
 func TestManager_InstalledVersions(t *testing.T) {
 	t.Run("empty", func(t *testing.T) {
 		mgr := testMgr(t, t.TempDir())
@@ -92,6 +148,189 @@ func TestManager_Install(t *testing.T) {
 	})
 }
 
+func TestManager_Install_mirror(t *testing.T) {
+	version := "17.1.0"
+	repo := fakeMavenRepo(t, version, map[string]string{"bin/pg_ctl": "pg_ctl"})
+	var phases []ProgressPhase
+	mgr := NewPGManager(PGMConfig{
+		CacheDir:  t.TempDir(),
+		MavenURLs: []string{repo.URL},
+		Progress: func(p Progress) {
+			require.Equal(t, version, p.Version)
+			require.LessOrEqual(t, p.Done, p.Total)
+			if !slices.Contains(phases, p.Phase) {
+				phases = append(phases, p.Phase)
+			}
+		},
+	})
+	require.NoError(t, mgr.Install(t.Context(), version))
+	require.Equal(t, []ProgressPhase{ProgressDownload, ProgressExtract}, phases)
+	bin, unlock, err := mgr.Bin(t.Context(), version)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, unlock()) })
+	b, err := os.ReadFile(filepath.Join(bin, "pg_ctl"))
+	require.NoError(t, err)
+	require.Equal(t, "pg_ctl", string(b))
+	b, err = os.ReadFile(checksumFile(filepath.Dir(bin)))
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(b), "sha1:"))
+	_, err = os.Stat(mgr.downloadPath(version))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestManager_Install_retry(t *testing.T) {
+	version := "17.1.0"
+	jar := fakeMavenJar(t, map[string]string{"bin/pg_ctl": "pg_ctl"})
+	repo := fakeMavenHandler(version, jar)
+
+	t.Run("transient errors", func(t *testing.T) {
+		var failures int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if failures < 2 {
+				failures++
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			repo.ServeHTTP(w, r)
+		}))
+		t.Cleanup(srv.Close)
+		mgr := NewPGManager(PGMConfig{CacheDir: t.TempDir(), MavenURLs: []string{srv.URL}})
+		mgr.retryBackoff = time.Millisecond
+		require.NoError(t, mgr.Install(t.Context(), version))
+		require.Equal(t, 2, failures)
+	})
+
+	t.Run("resumes partial download", func(t *testing.T) {
+		var ranges []string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, ".jar") {
+				ranges = append(ranges, r.Header.Get("Range"))
+			}
+			repo.ServeHTTP(w, r)
+		}))
+		t.Cleanup(srv.Close)
+		mgr := NewPGManager(PGMConfig{CacheDir: t.TempDir(), MavenURLs: []string{srv.URL}})
+		mgr.init()
+		partial := mgr.downloadPath(version)
+		require.NoError(t, os.MkdirAll(filepath.Dir(partial), 0o700))
+		require.NoError(t, os.WriteFile(partial, jar[:len(jar)/2], 0o600))
+		require.NoError(t, mgr.Install(t.Context(), version))
+		require.Equal(t, []string{fmt.Sprintf("bytes=%d-", len(jar)/2)}, ranges)
+		_, err := os.Stat(partial)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("corrupt partial download", func(t *testing.T) {
+		var ranges []string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, ".jar") {
+				ranges = append(ranges, r.Header.Get("Range"))
+			}
+			repo.ServeHTTP(w, r)
+		}))
+		t.Cleanup(srv.Close)
+		mgr := NewPGManager(PGMConfig{CacheDir: t.TempDir(), MavenURLs: []string{srv.URL}})
+		mgr.init()
+		partial := mgr.downloadPath(version)
+		require.NoError(t, os.MkdirAll(filepath.Dir(partial), 0o700))
+		require.NoError(t, os.WriteFile(partial, []byte("garbage"), 0o600))
+		require.NoError(t, mgr.Install(t.Context(), version))
+		require.Equal(t, []string{"bytes=7-", ""}, ranges)
+		_, err := os.Stat(partial)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("corrupt download", func(t *testing.T) {
+		var downloads int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasSuffix(r.URL.Path, ".jar") {
+				repo.ServeHTTP(w, r)
+				return
+			}
+			downloads++
+			_, err := w.Write([]byte("garbage"))
+			assert.NoError(t, err)
+		}))
+		t.Cleanup(srv.Close)
+		mgr := NewPGManager(PGMConfig{CacheDir: t.TempDir(), MavenURLs: []string{srv.URL}})
+		require.ErrorIs(t, mgr.Install(t.Context(), version), errChecksumMismatch)
+		// a fresh download that fails the checksum isn't downloaded again
+		require.Equal(t, 1, downloads)
+	})
+
+	t.Run("unreachable repository", func(t *testing.T) {
+		var attempts int
+		unreachable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			attempts++
+			conn, _, err := w.(http.Hijacker).Hijack()
+			assert.NoError(t, err)
+			assert.NoError(t, conn.Close())
+		}))
+		t.Cleanup(unreachable.Close)
+		srv := httptest.NewServer(repo)
+		t.Cleanup(srv.Close)
+		mgr := NewPGManager(PGMConfig{CacheDir: t.TempDir(), MavenURLs: []string{unreachable.URL, srv.URL}})
+		mgr.retryBackoff = time.Millisecond
+		require.NoError(t, mgr.Install(t.Context(), version))
+		// the mirror is tried before the unreachable repository is retried
+		require.Equal(t, 1, attempts)
+	})
+}
+
+// fakeMavenRepo serves a zonky style jar containing files for version along with its sha1 checksum.
+func fakeMavenRepo(t *testing.T, version string, files map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(fakeMavenHandler(version, fakeMavenJar(t, files)))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// fakeMavenJar builds a zonky style jar: a zip holding a txz of files.
+func fakeMavenJar(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	ctx := t.Context()
+	srcDir := t.TempDir()
+	filenames := map[string]string{}
+	for name, content := range files {
+		filename := filepath.Join(srcDir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(filename), 0o700))
+		require.NoError(t, os.WriteFile(filename, []byte(content), 0o700))
+		filenames[filename] = name
+	}
+	archiveFiles, err := archives.FilesFromDisk(ctx, nil, filenames)
+	require.NoError(t, err)
+	var txz bytes.Buffer
+	txzFormat := archives.CompressedArchive{Compression: archives.Xz{}, Archival: archives.Tar{}}
+	require.NoError(t, txzFormat.Archive(ctx, &txz, archiveFiles))
+	var jar bytes.Buffer
+	zw := zip.NewWriter(&jar)
+	w, err := zw.Create("postgres-linux-x86_64.txz")
+	require.NoError(t, err)
+	_, err = w.Write(txz.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return jar.Bytes()
+}
+
+// fakeMavenHandler serves jar and its sha1 checksum at the maven path for version.
+func fakeMavenHandler(version string, jar []byte) http.Handler {
+	artifactID := internal.SystemArtifactID(runtime.GOOS + "/" + runtime.GOARCH)
+	jarPath := fmt.Sprintf("/%s/%s/%s/%s-%s.jar", zonkyGroupID, artifactID, version, artifactID, version)
+	sum := sha1.Sum(jar)
+	content := map[string][]byte{
+		jarPath:           jar,
+		jarPath + ".sha1": []byte(hex.EncodeToString(sum[:])),
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, ok := content[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, path.Base(r.URL.Path), time.Time{}, bytes.NewReader(b))
+	})
+}
+
 func TestManager_Bin(t *testing.T) {
 	t.Run("cached", func(t *testing.T) {
 		mgr := testMgr(t, "")
@@ -0,0 +1,67 @@
+package pgdevserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	// maxAttempts is the number of times a transient failure is attempted before giving up.
+	maxAttempts = 5
+
+	defaultRetryBackoff = 500 * time.Millisecond
+	maxRetryBackoff     = 10 * time.Second
+)
+
+// httpStatusError is returned for unexpected http responses.
+type httpStatusError struct {
+	StatusCode int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("unexpected http status code %d", e.StatusCode)
+}
+
+// retry calls fn until it succeeds, fails with an error that isn't transient or has been attempted maxAttempts
+// times. The wait between attempts starts at m.retryBackoff and doubles each time.
+func (m *PGManager) retry(ctx context.Context, fn func() error) error {
+	backoff := m.retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt == maxAttempts || !transient(ctx, err) {
+			return err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+// transient reports whether err is worth retrying: a 5xx or 429 response, a timeout, a connection reset or a
+// truncated response body.
+func transient(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	// a host that doesn't exist won't start existing on the next attempt
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
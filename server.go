@@ -3,6 +3,7 @@ package pgdevserver
 import (
 	"cmp"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,18 +11,30 @@ import (
 	"os/exec"
 	"path/filepath"
 	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/adrg/xdg"
 	"github.com/willabides/pgdevserver/internal/bdcache"
 )
 
-const defaultPostgresVersion = "17.2.0"
+const (
+	defaultPostgresVersion = latestVersion
+	defaultPort            = "5432"
+)
 
 type Server struct {
-	config   Config
-	cache    bdcache.Cache
-	initOnce sync.Once
+	config Config
+	cache  bdcache.Cache
+	// existingOnly makes lookups fail with errServerNotFound instead of creating the server when its cache entry is
+	// missing or incomplete. Background work like idle watchers and gc must never bring a removed server back.
+	existingOnly bool
+	initOnce     sync.Once
+	resolveMu    sync.Mutex
+	resolved     bool
+	initErr      error
 }
 
 func New(cfg Config) *Server {
@@ -47,19 +60,137 @@ func (s *Server) Status(ctx context.Context) (Status, error) {
 func (s *Server) Start(ctx context.Context) error {
 	s.init()
 	return s.withCacheLock(ctx, func(cacheDir string) error {
-		return s.start(ctx, cacheDir)
+		return s.startLocked(ctx, cacheDir)
+	})
+}
+
+// Restart stops the server and starts it again, picking up settings that only take effect at server start.
+// A stopped server is simply started.
+func (s *Server) Restart(ctx context.Context) error {
+	s.init()
+	return s.withCacheLock(ctx, func(cacheDir string) error {
+		err := s.stopForRestart(ctx, cacheDir)
+		if err != nil {
+			return err
+		}
+		return s.startLocked(ctx, cacheDir)
 	})
 }
 
+// stopForRestart stops the server and its idle watcher before the caller starts it again with startLocked. A
+// watcher left running would exit once it saw the server stopped, possibly after startLocked found it still running
+// and didn't start a new one.
+func (s *Server) stopForRestart(ctx context.Context, cacheDir string) error {
+	err := stopIdleWatcher(cacheDir)
+	if err != nil {
+		return err
+	}
+	return s.stop(ctx, cacheDir)
+}
+
+// Reload signals the running server to reload its configuration files.
+func (s *Server) Reload(ctx context.Context) error {
+	s.init()
+	return s.withCacheLock(ctx, func(cacheDir string) (errOut error) {
+		binDir, unlock, err := s.bin(ctx, cacheDir)
+		if err != nil {
+			return err
+		}
+		defer func() { errOut = errors.Join(errOut, unlock()) }()
+		pgCtl := filepath.Join(binDir, "pg_ctl")
+		cmd := exec.CommandContext(ctx, pgCtl,
+			"reload",
+			"--silent",
+			"-D", filepath.Join(cacheDir, "data"),
+		)
+		err = execRun(cmd)
+		if err != nil {
+			return fmt.Errorf("running pg_ctl reload: %w", err)
+		}
+		return nil
+	})
+}
+
+// startLocked is Start for callers that already hold the cache lock.
+func (s *Server) startLocked(ctx context.Context, cacheDir string) error {
+	if s.config.IdleTimeout > 0 && !idleWatcherEnabled.Load() {
+		return errIdleWatcherDisabled
+	}
+	err := s.start(ctx, cacheDir)
+	if err != nil {
+		return err
+	}
+	err = writeTimestamp(lastStartedPath(cacheDir), time.Now())
+	if err != nil {
+		return err
+	}
+	err = s.runInitScripts(ctx, cacheDir)
+	if err != nil {
+		return err
+	}
+	return s.ensureIdleWatcher(cacheDir)
+}
+
 // ConnectionURL returns the current connection URL of this server.
 // When using dynamic ports, the ConnectionURL could change each time the server is started from a stopped state.
+// When DisableTCP is set, the URL points to the server's unix domain socket. Otherwise, when TLS is set, the URL
+// requires a TLS connection verified against the server's CA.
 func (s *Server) ConnectionURL(ctx context.Context) (string, error) {
+	return s.DatabaseURL(ctx, "")
+}
+
+// Port returns the port the server listens on. When using dynamic ports, this is the port that will be used the next
+// time the server starts from a stopped state.
+func (s *Server) Port(ctx context.Context) (string, error) {
+	s.init()
+	var port string
+	err := s.withCacheLock(ctx, func(cacheDir string) error {
+		var err error
+		port, err = s.port(cacheDir)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return port, nil
+}
+
+// DataDir returns the path to the server's data directory.
+func (s *Server) DataDir(ctx context.Context) (string, error) {
 	s.init()
-	port, err := s.getPort(ctx)
+	var dataDir string
+	err := s.withCacheLock(ctx, func(cacheDir string) error {
+		dataDir = filepath.Join(cacheDir, "data")
+		return nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("getting port: %w", err)
+		return "", err
 	}
-	return fmt.Sprintf("postgresql://postgres@localhost:%s", port), nil
+	return dataDir, nil
+}
+
+// PID returns the process ID of the running postgres server or 0 if it is not running.
+func (s *Server) PID(ctx context.Context) (int, error) {
+	s.init()
+	var pid int
+	err := s.withCacheLock(ctx, func(cacheDir string) error {
+		status, err := s.status(ctx, cacheDir)
+		if err != nil || status != StatusRunning {
+			return err
+		}
+		b, err := os.ReadFile(filepath.Join(cacheDir, "data", "postmaster.pid"))
+		if err != nil {
+			return err
+		}
+		// the first line of postmaster.pid is the pid
+		line, _, _ := strings.Cut(string(b), "\n")
+		pid, err = strconv.Atoi(strings.TrimSpace(line))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return pid, nil
 }
 
 // Logfile returns the path to the log file for the server.
@@ -93,19 +224,96 @@ func (s *Server) init() {
 	})
 }
 
-// Config returns the configuration of the server after it has been initialized with defaults.
-func (s *Server) Config() Config {
+// resolve resolves a PostgresVersion constraint to the exact version that is part of the server's ID. It only
+// reaches the network when no existing server, installed version or known version matches, so it waits for the
+// first method with a context. The result is kept unless ctx ended before resolving finished.
+func (s *Server) resolve(ctx context.Context) error {
 	s.init()
+	s.resolveMu.Lock()
+	defer s.resolveMu.Unlock()
+	if s.resolved {
+		return s.initErr
+	}
+	version, err := s.resolveVersion(ctx)
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// a later call with another context tries again
+		return fmt.Errorf("resolving postgres version: %w", err)
+	case err != nil:
+		s.initErr = fmt.Errorf("resolving postgres version: %w", err)
+	default:
+		s.config.PostgresVersion = version
+	}
+	s.resolved = true
+	return s.initErr
+}
+
+// resolveVersion resolves the configured postgres version. A constraint keeps matching the version of an existing
+// server with the same settings, so the server doesn't change each time a newer version is installed.
+func (s *Server) resolveVersion(ctx context.Context) (string, error) {
+	constraint := s.config.PostgresVersion
+	if isExactVersion(constraint) {
+		return constraint, nil
+	}
+	c, err := parseVersionConstraint(constraint)
+	if err != nil {
+		return "", err
+	}
+	existing, err := s.existingVersions()
+	if err != nil {
+		return "", err
+	}
+	version := newestMatch(c, existing)
+	if version != "" {
+		return version, nil
+	}
+	return s.config.PGManager.ResolveVersion(ctx, constraint)
+}
+
+// Err returns the error from initializing the server, such as a PostgresVersion constraint that no version
+// matches. Methods that return an error return it as well. ID and Config are only meaningful when Err is nil.
+// Like ID and Config, Err resolves PostgresVersion without a context if no method with a context has resolved it
+// yet.
+func (s *Server) Err() error {
+	return s.resolve(context.Background())
+}
+
+// Config returns the configuration of the server after it has been initialized with defaults. If Err is not nil,
+// PostgresVersion is the unresolved version from the original configuration.
+func (s *Server) Config() Config {
+	_ = s.resolve(context.Background())
 	return s.config.clone()
 }
 
-// ID returns a unique identifier for the server within the cache.
+// ID returns a unique identifier for the server within the cache. It is empty if Err is not nil.
 func (s *Server) ID() string {
-	s.init()
-	return s.Config().cacheKey()
+	if s.resolve(context.Background()) != nil {
+		return ""
+	}
+	return s.config.cacheKey()
+}
+
+// bin returns the directory of the server's postgres binaries. Once the server's data directory exists, a system
+// installation with the same major version can stand in for a PostgresVersion that is no longer installed.
+func (s *Server) bin(ctx context.Context, cacheDir string) (binDir string, unlock func() error, _ error) {
+	_, err := os.Stat(filepath.Join(cacheDir, "data", "PG_VERSION"))
+	if err == nil {
+		return s.config.PGManager.dataBin(ctx, s.config.PostgresVersion)
+	}
+	return s.config.PGManager.Bin(ctx, s.config.PostgresVersion)
 }
 
+// errServerNotFound is returned by lookups that don't create the server when its cache entry is missing or incomplete.
+var errServerNotFound = errors.New("server not found")
+
 func (s *Server) withCacheLock(ctx context.Context, fn func(cacheDir string) error) (errOut error) {
+	err := s.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	if s.existingOnly {
+		return s.withExistingCacheLock(ctx, fn)
+	}
 	populator := func(cacheDir string) error { return s.populateCache(ctx, cacheDir) }
 	cacheDir, unlock, err := s.cache.Dir(s.config.cacheKey(), validateServerCache, populator)
 	if err != nil {
@@ -115,8 +323,27 @@ func (s *Server) withCacheLock(ctx context.Context, fn func(cacheDir string) err
 	return fn(cacheDir)
 }
 
+// withExistingCacheLock is withCacheLock for a server that must already exist. It returns errServerNotFound
+// instead of creating the server.
+func (s *Server) withExistingCacheLock(ctx context.Context, fn func(cacheDir string) error) (errOut error) {
+	err := s.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	cacheDir, unlock, err := s.cache.Dir(s.config.cacheKey(), validateServerCache, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errServerNotFound, err)
+	}
+	defer func() { errOut = errors.Join(errOut, unlock()) }()
+	return fn(cacheDir)
+}
+
 func (s *Server) status(ctx context.Context, cacheDir string) (_ Status, errOut error) {
-	binDir, unlock, err := s.config.PGManager.Bin(ctx, s.config.PostgresVersion)
+	// Checking on a server must not download postgres. Without an installation to run it, it can't be running.
+	binDir, unlock, err := s.config.PGManager.installedBin(s.config.PostgresVersion)
+	if errors.Is(err, errNotInstalled) {
+		return StatusStopped, nil
+	}
 	if err != nil {
 		return 0, err
 	}
@@ -156,7 +383,7 @@ func (s *Server) start(ctx context.Context, cacheDir string) (errOut error) {
 	default:
 		return errors.New("cluster is in an invalid state")
 	}
-	port, err := getTcpPortFromFile(cacheDir)
+	port, err := s.port(cacheDir)
 	if err != nil {
 		return fmt.Errorf("getting port: %w", err)
 	}
@@ -172,10 +399,40 @@ func (s *Server) start(ctx context.Context, cacheDir string) (errOut error) {
 		"--options", fmt.Sprintf("-p %s", port),
 		"--log", logfile,
 	}
+	socketDir, err := s.socketDir(cacheDir)
+	if err != nil {
+		return err
+	}
+	if socketDir != "" {
+		err = os.MkdirAll(socketDir, 0o700)
+		if err != nil {
+			return fmt.Errorf("creating socket directory: %w", err)
+		}
+		args = append(args, "--option", "-c unix_socket_directories="+optionQuote(socketDir))
+	}
+	if s.config.DisableTCP {
+		args = append(args, "--option", "-c listen_addresses=''")
+	}
+	if s.config.TLS {
+		var files tlsFiles
+		files, err = serverTLSFiles(cacheDir)
+		if err != nil {
+			return err
+		}
+		err = files.ensure()
+		if err != nil {
+			return fmt.Errorf("generating tls certificates: %w", err)
+		}
+		args = append(args,
+			"--option", "-c ssl=on",
+			"--option", "-c ssl_cert_file="+optionQuote(files.serverCert),
+			"--option", "-c ssl_key_file="+optionQuote(files.serverKey),
+		)
+	}
 	for _, o := range s.config.PostgresOptions {
 		args = append(args, "--option", o)
 	}
-	binDir, unlock, err := s.config.PGManager.Bin(ctx, s.config.PostgresVersion)
+	binDir, unlock, err := s.bin(ctx, cacheDir)
 	if err != nil {
 		return err
 	}
@@ -184,7 +441,7 @@ func (s *Server) start(ctx context.Context, cacheDir string) (errOut error) {
 	cmd := exec.CommandContext(ctx, pgCtl, args...)
 	err = execRun(cmd)
 	if err != nil {
-		return fmt.Errorf("running pg_ctl start: %w", err)
+		return fmt.Errorf("running pg_ctl start (see %s for details): %w", logfile, err)
 	}
 	return nil
 }
@@ -194,6 +451,63 @@ func (s *Server) Create(ctx context.Context) error {
 	return s.withCacheLock(ctx, func(cacheDir string) error { return nil })
 }
 
+// Remove stops the server and its idle watcher and removes the server from the cache along with all of its data.
+func (s *Server) Remove(ctx context.Context) error {
+	s.init()
+	err := s.withExistingCacheLock(ctx, func(cacheDir string) error {
+		err := stopIdleWatcher(cacheDir)
+		if err != nil {
+			return err
+		}
+		return s.stop(ctx, cacheDir)
+	})
+	// a missing or incomplete entry has no server to stop
+	if err != nil && !errors.Is(err, errServerNotFound) {
+		return err
+	}
+	return s.cache.Evict(s.config.cacheKey())
+}
+
+// CreatedAt returns the time the server was created.
+func (s *Server) CreatedAt(ctx context.Context) (time.Time, error) {
+	s.init()
+	var created time.Time
+	err := s.withCacheLock(ctx, func(cacheDir string) error {
+		var err error
+		created, err = readTimestamp(createdAtPath(cacheDir))
+		if err != nil || !created.IsZero() {
+			return err
+		}
+		// servers created before timestamps were recorded still have a config file
+		info, err := os.Stat(configJSONPath(cacheDir))
+		if err != nil {
+			return err
+		}
+		created = info.ModTime()
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	return created, nil
+}
+
+// LastStarted returns the last time the server was started with Start. It returns the zero time if the server has
+// never been started.
+func (s *Server) LastStarted(ctx context.Context) (time.Time, error) {
+	s.init()
+	var started time.Time
+	err := s.withCacheLock(ctx, func(cacheDir string) error {
+		var err error
+		started, err = readTimestamp(lastStartedPath(cacheDir))
+		return err
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	return started, nil
+}
+
 func (s *Server) Stop(ctx context.Context) error {
 	s.init()
 	return s.withCacheLock(ctx, func(cacheDir string) error {
@@ -210,7 +524,7 @@ func (s *Server) stop(ctx context.Context, cacheDir string) (errOut error) {
 	if status == StatusStopped {
 		return nil
 	}
-	binDir, unlock, err := s.config.PGManager.Bin(ctx, s.config.PostgresVersion)
+	binDir, unlock, err := s.bin(ctx, cacheDir)
 	if err != nil {
 		return err
 	}
@@ -228,20 +542,104 @@ func (s *Server) stop(ctx context.Context, cacheDir string) (errOut error) {
 	return nil
 }
 
-func (s *Server) getPort(ctx context.Context) (string, error) {
-	if s.config.Port != "" {
-		return s.config.Port, nil
-	}
-	var port string
+// connParams returns the parameters for connecting to dbname on this server. An empty dbname connects to the
+// postgres database, which initdb always creates.
+func (s *Server) connParams(ctx context.Context, dbname string) (connParams, error) {
+	var params connParams
 	err := s.withCacheLock(ctx, func(cacheDir string) error {
 		var err error
-		port, err = getTcpPortFromFile(cacheDir)
+		params, err = s.cacheDirConnParams(cacheDir, dbname)
 		return err
 	})
 	if err != nil {
-		return "", err
+		return connParams{}, err
 	}
-	return port, nil
+	return params, nil
+}
+
+// cacheDirConnParams is connParams for callers that already hold the cache lock.
+func (s *Server) cacheDirConnParams(cacheDir, dbname string) (connParams, error) {
+	// The default database is named after the user, so it only needs to be explicit for other users.
+	if dbname == "" && s.config.user() != "postgres" {
+		dbname = "postgres"
+	}
+	params := connParams{
+		host:     "localhost",
+		user:     s.config.user(),
+		password: s.config.Password,
+		dbname:   dbname,
+	}
+	var err error
+	params.port, err = s.port(cacheDir)
+	if err != nil {
+		return connParams{}, fmt.Errorf("getting port: %w", err)
+	}
+	if s.config.DisableTCP {
+		params.host, err = s.socketDir(cacheDir)
+		if err != nil {
+			return connParams{}, err
+		}
+		return params, nil
+	}
+	if s.config.TLS {
+		files, err := serverTLSFiles(cacheDir)
+		if err != nil {
+			return connParams{}, err
+		}
+		params.sslRootCert = files.caCert
+	}
+	return params, nil
+}
+
+// port returns the port the server listens on. Without TCP there is nothing to conflict with, so the
+// default postgres port is used.
+func (s *Server) port(cacheDir string) (string, error) {
+	switch {
+	case s.config.Port != "":
+		return s.config.Port, nil
+	case s.config.DisableTCP:
+		return defaultPort, nil
+	default:
+		return getTcpPortFromFile(cacheDir)
+	}
+}
+
+// socketDir returns the absolute path of the directory for the server's unix domain socket or "" to use the
+// postgres default. System postgres packages default to a directory like /var/run/postgresql that other users
+// can't write to, so those get a socket directory in the cache too.
+func (s *Server) socketDir(cacheDir string) (string, error) {
+	switch {
+	case s.config.SocketDir != "":
+		return filepath.Abs(s.config.SocketDir)
+	case s.config.DisableTCP, s.config.PGManager.systemBin(s.config.PostgresVersion) != "":
+		dir, err := filepath.Abs(filepath.Join(cacheDir, "socket"))
+		if err != nil {
+			return "", err
+		}
+		return shortSocketDir(dir), nil
+	default:
+		return "", nil
+	}
+}
+
+// maxSocketPathLen is the longest unix socket path that fits in sun_path on every supported platform. macOS has the
+// smallest at 104 bytes including the terminating NUL.
+const maxSocketPathLen = 103
+
+// shortSocketDir returns dir if a postgres socket in it fits in sun_path. Otherwise, it returns a directory under
+// os.TempDir named for a hash of dir so that every lookup for the same server finds the same socket.
+func shortSocketDir(dir string) string {
+	if len(filepath.Join(dir, ".s.PGSQL.65535")) <= maxSocketPathLen {
+		return dir
+	}
+	sum := sha256.Sum256([]byte(dir))
+	return filepath.Join(os.TempDir(), fmt.Sprintf("pgdevserver-%x", sum[:8]))
+}
+
+// optionQuote quotes s for the postgres command line. pg_ctl runs postgres with /bin/sh, so the quoting is for
+// POSIX shells.
+func optionQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
 }
 
 func (s *Server) writeConfigFile(cacheDir string) (errOut error) {
@@ -250,7 +648,8 @@ func (s *Server) writeConfigFile(cacheDir string) (errOut error) {
 	if err != nil {
 		return err
 	}
-	f, err := os.Create(configFile)
+	// The config includes the superuser's password.
+	f, err := os.OpenFile(configFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
 	if err != nil {
 		return err
 	}
@@ -266,9 +665,27 @@ func (s *Server) populateCache(ctx context.Context, cacheDir string) (errOut err
 	if err != nil {
 		return err
 	}
+	err = writeTimestamp(createdAtPath(cacheDir), time.Now())
+	if err != nil {
+		return err
+	}
+	err = validateAuthMethod(s.config.AuthMethod, s.config.Password)
+	if err != nil {
+		return err
+	}
 	var args []string
 	args = appendFlagArg(args, "--pgdata", dataDir)
-	args = appendFlagArg(args, "--username", "postgres")
+	args = appendFlagArg(args, "--username", s.config.user())
+	args = appendFlagArg(args, "--auth", s.config.AuthMethod)
+	if s.config.Password != "" {
+		var pwFile string
+		pwFile, err = writePasswordFile(cacheDir, s.config.Password)
+		if err != nil {
+			return fmt.Errorf("writing password file: %w", err)
+		}
+		defer func() { errOut = errors.Join(errOut, os.Remove(pwFile)) }()
+		args = appendFlagArg(args, "--pwfile", pwFile)
+	}
 	args = append(args, s.config.InitDBArgs...)
 	binDir, unlock, err := s.config.PGManager.Bin(ctx, s.config.PostgresVersion)
 	if err != nil {
@@ -281,7 +698,24 @@ func (s *Server) populateCache(ctx context.Context, cacheDir string) (errOut err
 	if err != nil {
 		return fmt.Errorf("running initdb: %w", err)
 	}
-	return nil
+	if s.initScripts() == nil {
+		return nil
+	}
+	return markInitScriptsPending(cacheDir)
+}
+
+// writePasswordFile writes password to a temporary file in cacheDir for initdb's --pwfile option.
+func writePasswordFile(cacheDir, password string) (_ string, errOut error) {
+	f, err := os.CreateTemp(cacheDir, "pwfile")
+	if err != nil {
+		return "", err
+	}
+	defer func() { errOut = errors.Join(errOut, f.Close()) }()
+	_, err = f.WriteString(password + "\n")
+	if err != nil {
+		return "", err
+	}
+	return f.Name(), nil
 }
 
 func validateServerCache(cacheDir string) error {
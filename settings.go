@@ -0,0 +1,59 @@
+package pgdevserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SetParameter persists a postgres configuration parameter with ALTER SYSTEM and reloads the server's
+// configuration. Unlike PostgresOptions, parameters set this way are not part of the server's ID, so they can be
+// changed without creating a new server. The server must be running.
+//
+// Parameters set on the postgres command line, like PostgresOptions and the port and socket settings pgdevserver
+// passes itself, take precedence over ALTER SYSTEM. SetParameter returns an error instead of persisting a value
+// that would never take effect.
+//
+// The returned bool reports whether the parameter only takes effect when the server is restarted with Restart.
+func (s *Server) SetParameter(ctx context.Context, name, value string) (_ bool, errOut error) {
+	s.init()
+	if name == "" {
+		return false, errors.New("parameter name is required")
+	}
+	u, err := s.ConnectionURL(ctx)
+	if err != nil {
+		return false, err
+	}
+	conn, err := pgx.Connect(ctx, u)
+	if err != nil {
+		return false, err
+	}
+	defer func() { errOut = errors.Join(errOut, conn.Close(ctx)) }()
+	// Placeholder parameters for extensions that aren't loaded have no pg_settings row.
+	var restart, pinned bool
+	err = conn.QueryRow(ctx, `
+		SELECT coalesce(bool_or(context = 'postmaster'), false), coalesce(bool_or(source = 'command line'), false)
+		FROM pg_settings WHERE name = lower($1)`, name,
+	).Scan(&restart, &pinned)
+	if err != nil {
+		return false, err
+	}
+	if pinned {
+		return false, fmt.Errorf("%s is pinned by a server option and can't be changed with ALTER SYSTEM", name)
+	}
+	// qualified names like auto_explain.log_min_duration are dotted identifiers
+	ident := pgx.Identifier(strings.Split(name, ".")).Sanitize()
+	stmt := fmt.Sprintf("ALTER SYSTEM SET %s TO '%s'", ident, strings.ReplaceAll(value, "'", "''"))
+	_, err = conn.Exec(ctx, stmt)
+	if err != nil {
+		return false, fmt.Errorf("setting %s: %w", name, err)
+	}
+	_, err = conn.Exec(ctx, "SELECT pg_reload_conf()")
+	if err != nil {
+		return false, fmt.Errorf("reloading configuration: %w", err)
+	}
+	return restart, nil
+}
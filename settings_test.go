@@ -0,0 +1,85 @@
+package pgdevserver
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_SetParameter(t *testing.T) {
+	ctx := context.Background()
+	srv := New(Config{
+		PostgresVersion: "17.1.0",
+		CacheDir:        filepath.Join(testCacheDir, "TestServer_SetParameter"),
+	})
+	require.NoError(t, srv.Start(ctx))
+	t.Cleanup(func() { require.NoError(t, srv.Stop(ctx)) })
+	resetParametersOnCleanup(t, srv)
+	show := func(name string) string {
+		u, err := srv.ConnectionURL(ctx)
+		require.NoError(t, err)
+		conn, err := pgx.Connect(ctx, u)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, conn.Close(ctx)) }()
+		var value string
+		require.NoError(t, conn.QueryRow(ctx, "SHOW "+name).Scan(&value))
+		return value
+	}
+
+	restart, err := srv.SetParameter(ctx, "work_mem", "8MB")
+	require.NoError(t, err)
+	require.False(t, restart)
+	require.Eventually(t, func() bool { return show("work_mem") == "8MB" }, 5*time.Second, 100*time.Millisecond)
+
+	restart, err = srv.SetParameter(ctx, "shared_buffers", "32MB")
+	require.NoError(t, err)
+	require.True(t, restart)
+	require.NoError(t, srv.Reload(ctx))
+	require.NotEqual(t, "32MB", show("shared_buffers"))
+	require.NoError(t, srv.Restart(ctx))
+	require.Equal(t, "32MB", show("shared_buffers"))
+	require.Equal(t, "8MB", show("work_mem"))
+
+	_, err = srv.SetParameter(ctx, "not_a_real_parameter", "1")
+	require.Error(t, err)
+}
+
+func TestServer_SetParameter_pinned(t *testing.T) {
+	ctx := context.Background()
+	srv := New(Config{
+		PostgresVersion: "17.1.0",
+		PostgresOptions: []string{"-c work_mem=4MB"},
+		CacheDir:        filepath.Join(testCacheDir, "TestServer_SetParameter_pinned"),
+	})
+	require.NoError(t, srv.Start(ctx))
+	t.Cleanup(func() { require.NoError(t, srv.Stop(ctx)) })
+	resetParametersOnCleanup(t, srv)
+
+	_, err := srv.SetParameter(ctx, "work_mem", "8MB")
+	require.ErrorContains(t, err, "pinned by a server option")
+	_, err = srv.SetParameter(ctx, "port", "5499")
+	require.ErrorContains(t, err, "pinned by a server option")
+
+	_, err = srv.SetParameter(ctx, "maintenance_work_mem", "32MB")
+	require.NoError(t, err)
+}
+
+// resetParametersOnCleanup removes everything set with ALTER SYSTEM before srv is stopped. The server's cache
+// outlives the test, so the next run would otherwise start with the parameters already set.
+func resetParametersOnCleanup(t *testing.T, srv *Server) {
+	t.Helper()
+	t.Cleanup(func() {
+		ctx := context.Background()
+		u, err := srv.ConnectionURL(ctx)
+		require.NoError(t, err)
+		conn, err := pgx.Connect(ctx, u)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, conn.Close(ctx)) }()
+		_, err = conn.Exec(ctx, "ALTER SYSTEM RESET ALL")
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,194 @@
+package pgdevserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Snapshot saves a copy of the server's data directory under the given name, replacing any existing snapshot
+// with the same name. A running server is stopped while the copy is made and started again afterward.
+func (s *Server) Snapshot(ctx context.Context, name string) error {
+	s.init()
+	err := validateSnapshotName(name)
+	if err != nil {
+		return err
+	}
+	return s.withCacheLock(ctx, func(cacheDir string) error {
+		return s.whileStopped(ctx, cacheDir, func() error {
+			dest := snapshotPath(cacheDir, name)
+			tmp := dest + ".tmp"
+			err := os.RemoveAll(tmp)
+			if err != nil {
+				return err
+			}
+			err = copyDir(filepath.Join(cacheDir, "data"), tmp)
+			if err != nil {
+				return fmt.Errorf("copying data directory: %w", err)
+			}
+			err = os.RemoveAll(dest)
+			if err != nil {
+				return err
+			}
+			return os.Rename(tmp, dest)
+		})
+	})
+}
+
+// Restore replaces the server's data directory with the named snapshot. A running server is stopped while the
+// data directory is replaced and started again afterward.
+func (s *Server) Restore(ctx context.Context, name string) error {
+	s.init()
+	err := validateSnapshotName(name)
+	if err != nil {
+		return err
+	}
+	return s.withCacheLock(ctx, func(cacheDir string) error {
+		src := snapshotPath(cacheDir, name)
+		_, err := os.Stat(src)
+		if err != nil {
+			return fmt.Errorf("snapshot %s: %w", name, err)
+		}
+		return s.whileStopped(ctx, cacheDir, func() error {
+			dataDir := filepath.Join(cacheDir, "data")
+			tmp := dataDir + ".restore"
+			old := dataDir + ".old"
+			err := errors.Join(os.RemoveAll(tmp), os.RemoveAll(old))
+			if err != nil {
+				return err
+			}
+			err = copyDir(src, tmp)
+			if err != nil {
+				return fmt.Errorf("copying snapshot: %w", err)
+			}
+			err = os.Rename(dataDir, old)
+			if err != nil {
+				return err
+			}
+			err = os.Rename(tmp, dataDir)
+			if err != nil {
+				return errors.Join(err, os.Rename(old, dataDir))
+			}
+			return os.RemoveAll(old)
+		})
+	})
+}
+
+// Snapshots returns the names of the server's snapshots.
+func (s *Server) Snapshots(ctx context.Context) ([]string, error) {
+	s.init()
+	var names []string
+	err := s.withCacheLock(ctx, func(cacheDir string) error {
+		entries, err := os.ReadDir(filepath.Join(cacheDir, "snapshots"))
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			return nil
+		case err != nil:
+			return err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || validateSnapshotName(entry.Name()) != nil {
+				continue
+			}
+			names = append(names, entry.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// RemoveSnapshot removes the named snapshot. It is not an error if the snapshot does not exist.
+func (s *Server) RemoveSnapshot(ctx context.Context, name string) error {
+	s.init()
+	err := validateSnapshotName(name)
+	if err != nil {
+		return err
+	}
+	return s.withCacheLock(ctx, func(cacheDir string) error {
+		return os.RemoveAll(snapshotPath(cacheDir, name))
+	})
+}
+
+// whileStopped runs fn with the server stopped. If the server was running, it is started again when fn returns.
+func (s *Server) whileStopped(ctx context.Context, cacheDir string, fn func() error) error {
+	status, err := s.status(ctx, cacheDir)
+	if err != nil {
+		return err
+	}
+	if status == StatusRunning {
+		err = s.stopForRestart(ctx, cacheDir)
+		if err != nil {
+			return err
+		}
+	}
+	err = fn()
+	if status != StatusRunning {
+		return err
+	}
+	return errors.Join(err, s.startLocked(ctx, cacheDir))
+}
+
+func snapshotPath(cacheDir, name string) string {
+	return filepath.Join(cacheDir, "snapshots", name)
+}
+
+func validateSnapshotName(name string) error {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") ||
+		strings.HasSuffix(name, ".tmp") {
+		return fmt.Errorf("invalid snapshot name %q", name)
+	}
+	return nil
+}
+
+// copyDir recursively copies src to dest, preserving file modes and symlinks.
+func copyDir(src, dest string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			return copyFile(p, target, info.Mode().Perm())
+		}
+	})
+}
+
+func copyFile(src, dest string, perm fs.FileMode) (errOut error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { errOut = errors.Join(errOut, in.Close()) }()
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer func() { errOut = errors.Join(errOut, out.Close()) }()
+	_, err = io.Copy(out, in)
+	return err
+}
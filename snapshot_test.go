@@ -0,0 +1,63 @@
+package pgdevserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Snapshot(t *testing.T) {
+	ctx := context.Background()
+	srv := New(Config{
+		PostgresVersion: "17.1.0",
+		CacheDir:        filepath.Join(testCacheDir, "TestServer_Snapshot"),
+	})
+	require.NoError(t, srv.Start(ctx))
+	t.Cleanup(func() { require.NoError(t, srv.Stop(ctx)) })
+	u, err := srv.ConnectionURL(ctx)
+	require.NoError(t, err)
+	exec := func(stmt string) error {
+		conn, err := pgx.Connect(ctx, u)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, conn.Close(ctx)) }()
+		_, err = conn.Exec(ctx, stmt)
+		return err
+	}
+
+	require.NoError(t, exec("DROP TABLE IF EXISTS foo"))
+	require.NoError(t, srv.Snapshot(ctx, "empty"))
+	t.Cleanup(func() { require.NoError(t, srv.RemoveSnapshot(ctx, "empty")) })
+	names, err := srv.Snapshots(ctx)
+	require.NoError(t, err)
+	require.Contains(t, names, "empty")
+
+	require.NoError(t, exec("CREATE TABLE foo (id int)"))
+	require.NoError(t, srv.Restore(ctx, "empty"))
+	status, err := srv.Status(ctx)
+	require.NoError(t, err)
+	require.Equal(t, StatusRunning, status)
+	require.Error(t, exec("SELECT * FROM foo"))
+}
+
+func Test_copyDir(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "a", "b"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "a", "b", "file"), []byte("hello"), 0o600))
+	require.NoError(t, os.Symlink("b/file", filepath.Join(src, "a", "link")))
+
+	dest := filepath.Join(t.TempDir(), "dest")
+	require.NoError(t, copyDir(src, dest))
+	got, err := os.ReadFile(filepath.Join(dest, "a", "b", "file"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(got))
+	info, err := os.Stat(filepath.Join(dest, "a", "b", "file"))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	link, err := os.Readlink(filepath.Join(dest, "a", "link"))
+	require.NoError(t, err)
+	require.Equal(t, "b/file", link)
+}
@@ -0,0 +1,171 @@
+package pgdevserver
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/willabides/pgdevserver/internal"
+)
+
+// SystemPostgresMode controls whether a PGManager uses postgres installations found on the system.
+type SystemPostgresMode int
+
+const (
+	// SystemPostgresNever only uses postgres binaries downloaded from maven. This is the default.
+	SystemPostgresNever SystemPostgresMode = iota
+	// SystemPostgresPrefer uses a system installation when one has the requested version and downloads otherwise.
+	SystemPostgresPrefer
+	// SystemPostgresOnly uses system installations and never downloads.
+	SystemPostgresOnly
+)
+
+func (m SystemPostgresMode) String() string {
+	switch m {
+	case SystemPostgresNever:
+		return "never"
+	case SystemPostgresPrefer:
+		return "prefer"
+	case SystemPostgresOnly:
+		return "only"
+	default:
+		return "unknown"
+	}
+}
+
+// parseSystemPostgresMode parses the String form of a SystemPostgresMode. An empty string is SystemPostgresNever.
+func parseSystemPostgresMode(s string) (SystemPostgresMode, error) {
+	for _, mode := range []SystemPostgresMode{SystemPostgresNever, SystemPostgresPrefer, SystemPostgresOnly} {
+		if s == mode.String() {
+			return mode, nil
+		}
+	}
+	if s == "" {
+		return SystemPostgresNever, nil
+	}
+	return 0, fmt.Errorf("unknown system postgres mode %q", s)
+}
+
+// systemPGBinEnv is a list of additional postgres bin directories separated by os.PathListSeparator.
+const systemPGBinEnv = "PGDEVSERVER_PG_BIN"
+
+// systemPGGlob matches the bin directories of debian and ubuntu postgres packages.
+var systemPGGlob = "/usr/lib/postgresql/*/bin"
+
+// postgresVersionRegexp matches the version in the output of postgres --version, such as
+// "postgres (PostgreSQL) 16.4 (Ubuntu 16.4-1.pgdg22.04+1)".
+var postgresVersionRegexp = regexp.MustCompile(`\(PostgreSQL\) (\d+)\.(\d+)(?:\.(\d+))?`)
+
+// systemBin returns the bin directory of the system installation of version or "" if there is none.
+func (m *PGManager) systemBin(version string) string {
+	if m.config.SystemPostgres == SystemPostgresNever {
+		return ""
+	}
+	m.systemOnce.Do(func() {
+		m.systemBins = findSystemPostgres()
+	})
+	return m.systemBins[version]
+}
+
+// systemMajorBin returns the bin directory of the newest system installation with the same major version as version
+// or "" if there is none.
+func (m *PGManager) systemMajorBin(version string) string {
+	major := pgMajorVersion(version)
+	if major == "" {
+		return ""
+	}
+	versions := slices.DeleteFunc(m.systemVersions(), func(v string) bool {
+		return pgMajorVersion(v) != major
+	})
+	if len(versions) == 0 {
+		return ""
+	}
+	internal.SortVersions(versions)
+	return m.systemBin(versions[len(versions)-1])
+}
+
+// pgMajorVersion returns the major version of a postgres version, which is the first part since postgres 10 and
+// the first two parts before that. It returns "" for an invalid version.
+func pgMajorVersion(version string) string {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return ""
+	}
+	if v.Major() >= 10 {
+		return strconv.FormatUint(v.Major(), 10)
+	}
+	return fmt.Sprintf("%d.%d", v.Major(), v.Minor())
+}
+
+// systemVersions returns the versions of postgres installed on the system.
+func (m *PGManager) systemVersions() []string {
+	if m.config.SystemPostgres == SystemPostgresNever {
+		return nil
+	}
+	m.systemBin("")
+	versions := make([]string, 0, len(m.systemBins))
+	for version := range m.systemBins {
+		versions = append(versions, version)
+	}
+	return versions
+}
+
+// findSystemPostgres returns the bin directories of postgres installations keyed by version. Directories in
+// PGDEVSERVER_PG_BIN take precedence over the one reported by pg_config on PATH, which takes precedence over
+// distribution packages.
+func findSystemPostgres() map[string]string {
+	var dirs []string
+	for _, dir := range filepath.SplitList(os.Getenv(systemPGBinEnv)) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	pgConfig, err := exec.LookPath("pg_config")
+	if err == nil {
+		out, err := exec.Command(pgConfig, "--bindir").Output()
+		if err == nil {
+			dirs = append(dirs, strings.TrimSpace(string(out)))
+		}
+	}
+	globbed, err := filepath.Glob(systemPGGlob)
+	if err == nil {
+		dirs = append(dirs, globbed...)
+	}
+	bins := map[string]string{}
+	for _, dir := range dirs {
+		version, err := systemPostgresVersion(dir)
+		if err != nil {
+			continue
+		}
+		if _, ok := bins[version]; !ok {
+			bins[version] = dir
+		}
+	}
+	return bins
+}
+
+// systemPostgresVersion returns the version of the postgres installation in binDir in the same major.minor.patch
+// form as the downloaded versions.
+func systemPostgresVersion(binDir string) (string, error) {
+	_, err := os.Stat(filepath.Join(binDir, "pg_ctl"))
+	if err != nil {
+		return "", err
+	}
+	out, err := exec.Command(filepath.Join(binDir, "postgres"), "--version").Output()
+	if err != nil {
+		return "", err
+	}
+	match := postgresVersionRegexp.FindStringSubmatch(string(out))
+	if match == nil {
+		return "", fmt.Errorf("unrecognized postgres version %q", strings.TrimSpace(string(out)))
+	}
+	// Since postgres 10, versions only have two parts.
+	return fmt.Sprintf("%s.%s.%s", match[1], match[2], cmp.Or(match[3], "0")), nil
+}
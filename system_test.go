@@ -0,0 +1,162 @@
+package pgdevserver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSystemPostgres creates a bin directory with a postgres that reports version.
+func fakeSystemPostgres(t *testing.T, version string) string {
+	t.Helper()
+	binDir := filepath.Join(t.TempDir(), "bin")
+	require.NoError(t, os.MkdirAll(binDir, 0o700))
+	script := fmt.Sprintf("#!/bin/sh\necho 'postgres (PostgreSQL) %s (Debian %s-1.pgdg120+1)'\n", version, version)
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "postgres"), []byte(script), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "pg_ctl"), []byte("#!/bin/sh\n"), 0o700))
+	return binDir
+}
+
+func TestManager_systemPostgres(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake binaries are shell scripts")
+	}
+	envBin := fakeSystemPostgres(t, "16.4")
+	pgConfigBin := fakeSystemPostgres(t, "15.8")
+	globBin := fakeSystemPostgres(t, "16.4")
+	pathDir := t.TempDir()
+	pgConfig := fmt.Sprintf("#!/bin/sh\necho %s\n", pgConfigBin)
+	require.NoError(t, os.WriteFile(filepath.Join(pathDir, "pg_config"), []byte(pgConfig), 0o700))
+	t.Setenv("PATH", pathDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv(systemPGBinEnv, envBin)
+	oldGlob := systemPGGlob
+	systemPGGlob = filepath.Join(filepath.Dir(globBin), "b*")
+	t.Cleanup(func() { systemPGGlob = oldGlob })
+
+	newMgr := func(mode SystemPostgresMode) *PGManager {
+		return NewPGManager(PGMConfig{CacheDir: t.TempDir(), SystemPostgres: mode})
+	}
+
+	t.Run("never", func(t *testing.T) {
+		versions, err := newMgr(SystemPostgresNever).InstalledVersions()
+		require.NoError(t, err)
+		require.Empty(t, versions)
+	})
+
+	t.Run("prefer", func(t *testing.T) {
+		mgr := newMgr(SystemPostgresPrefer)
+		versions, err := mgr.InstalledVersions()
+		require.NoError(t, err)
+		require.Equal(t, []string{"15.8.0", "16.4.0"}, versions)
+		version, err := mgr.ResolveVersion(t.Context(), "16")
+		require.NoError(t, err)
+		require.Equal(t, "16.4.0", version)
+		require.NoError(t, mgr.Install(t.Context(), version))
+		bin, unlock, err := mgr.Bin(t.Context(), version)
+		require.NoError(t, err)
+		require.NoError(t, unlock())
+		// PGDEVSERVER_PG_BIN wins over the package directory with the same version
+		require.Equal(t, envBin, bin)
+		bin, unlock, err = mgr.Bin(t.Context(), "15.8.0")
+		require.NoError(t, err)
+		require.NoError(t, unlock())
+		require.Equal(t, pgConfigBin, bin)
+	})
+
+	t.Run("only", func(t *testing.T) {
+		mgr := newMgr(SystemPostgresOnly)
+		version, err := mgr.ResolveVersion(t.Context(), "latest")
+		require.NoError(t, err)
+		require.Equal(t, "16.4.0", version)
+		_, err = mgr.ResolveVersion(t.Context(), "17")
+		require.Error(t, err)
+		_, _, err = mgr.Bin(t.Context(), "17.2.0")
+		require.Error(t, err)
+		require.Error(t, mgr.Install(t.Context(), "17.2.0"))
+	})
+
+	t.Run("minor upgrade", func(t *testing.T) {
+		// data directories created before the system packages were upgraded keep working
+		mgr := newMgr(SystemPostgresOnly)
+		bin, unlock, err := mgr.dataBin(t.Context(), "16.2.0")
+		require.NoError(t, err)
+		require.NoError(t, unlock())
+		require.Equal(t, envBin, bin)
+		bin, unlock, err = mgr.dataBin(t.Context(), "15.10.0")
+		require.NoError(t, err)
+		require.NoError(t, unlock())
+		require.Equal(t, pgConfigBin, bin)
+		_, _, err = mgr.dataBin(t.Context(), "14.8.0")
+		require.Error(t, err)
+		// new servers get the version they ask for
+		_, _, err = mgr.Bin(t.Context(), "16.2.0")
+		require.Error(t, err)
+	})
+
+	t.Run("downloaded minor version", func(t *testing.T) {
+		mgr := newMgr(SystemPostgresPrefer)
+		mgr.init()
+		downloaded := filepath.Join(mgr.config.CacheDir, pgCacheKey("16.2.0"), "bin")
+		require.NoError(t, os.MkdirAll(downloaded, 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(downloaded, "pg_ctl"), []byte("#!/bin/sh\n"), 0o700))
+		bin, unlock, err := mgr.dataBin(t.Context(), "16.2.0")
+		require.NoError(t, err)
+		require.NoError(t, unlock())
+		require.Equal(t, downloaded, bin)
+	})
+
+	t.Run("server data directory", func(t *testing.T) {
+		cacheDir := t.TempDir()
+		mgr := newMgr(SystemPostgresOnly)
+		srv := fakeServerCache(t, cacheDir, Config{PostgresVersion: "16.2.0", PGManager: mgr}, time.Now())
+		dir := filepath.Join(cacheDir, "server", srv.ID())
+		bin, unlock, err := srv.bin(t.Context(), dir)
+		require.NoError(t, err)
+		require.NoError(t, unlock())
+		require.Equal(t, envBin, bin)
+		require.NoError(t, os.Remove(filepath.Join(dir, "data", "PG_VERSION")))
+		_, _, err = srv.bin(t.Context(), dir)
+		require.Error(t, err)
+	})
+
+	t.Run("prefer downloads other minor versions", func(t *testing.T) {
+		repo := fakeMavenRepo(t, "16.2.0", map[string]string{"bin/pg_ctl": "pg_ctl"})
+		mgr := NewPGManager(PGMConfig{
+			CacheDir:       t.TempDir(),
+			MavenURLs:      []string{repo.URL},
+			SystemPostgres: SystemPostgresPrefer,
+		})
+		bin, unlock, err := mgr.Bin(t.Context(), "16.2.0")
+		require.NoError(t, err)
+		require.NoError(t, unlock())
+		require.Equal(t, filepath.Join(mgr.config.CacheDir, pgCacheKey("16.2.0"), "bin"), bin)
+	})
+}
+
+func Test_pgMajorVersion(t *testing.T) {
+	for version, want := range map[string]string{
+		"17.2.0":    "17",
+		"16.10.0":   "16",
+		"11.10.0-1": "11",
+		"9.6.24":    "9.6",
+		"bogus":     "",
+	} {
+		require.Equal(t, want, pgMajorVersion(version), version)
+	}
+}
+
+func Test_postgresVersionRegexp(t *testing.T) {
+	for out, want := range map[string][]string{
+		"postgres (PostgreSQL) 16.4 (Ubuntu 16.4-1.pgdg22.04+1)\n": {"16", "4", ""},
+		"postgres (PostgreSQL) 9.6.24\n":                           {"9", "6", "24"},
+		"postgres (PostgreSQL) 17.2\n":                             {"17", "2", ""},
+	} {
+		match := postgresVersionRegexp.FindStringSubmatch(out)
+		require.Equal(t, want, match[1:], out)
+	}
+}
@@ -0,0 +1,114 @@
+package pgdevserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const tlsValidity = 10 * 365 * 24 * time.Hour
+
+// tlsFiles are the paths of the certificates and keys used when Config.TLS is set.
+type tlsFiles struct {
+	caCert     string
+	serverCert string
+	serverKey  string
+}
+
+// serverTLSFiles returns absolute paths for the server's TLS files.
+func serverTLSFiles(cacheDir string) (tlsFiles, error) {
+	dir, err := filepath.Abs(filepath.Join(cacheDir, "tls"))
+	if err != nil {
+		return tlsFiles{}, err
+	}
+	return tlsFiles{
+		caCert:     filepath.Join(dir, "ca.crt"),
+		serverCert: filepath.Join(dir, "server.crt"),
+		serverKey:  filepath.Join(dir, "server.key"),
+	}, nil
+}
+
+// ensure generates a CA and a server certificate signed by it unless they already exist. The CA key is discarded
+// after signing because nothing else needs to be signed by it.
+func (f tlsFiles) ensure() error {
+	missing := false
+	for _, p := range []string{f.caCert, f.serverCert, f.serverKey} {
+		_, err := os.Stat(p)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			missing = true
+		case err != nil:
+			return err
+		}
+	}
+	if !missing {
+		return nil
+	}
+	err := os.MkdirAll(filepath.Dir(f.caCert), 0o700)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "pgdevserver CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(tlsValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return err
+	}
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serverTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(tlsValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	serverDER, err := x509.CreateCertificate(rand.Reader, serverTemplate, caCert, &serverKey.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+	serverKeyDER, err := x509.MarshalPKCS8PrivateKey(serverKey)
+	if err != nil {
+		return err
+	}
+	// postgres refuses to use a key file that is readable by group or others.
+	return errors.Join(
+		writePEM(f.caCert, "CERTIFICATE", caDER),
+		writePEM(f.serverCert, "CERTIFICATE", serverDER),
+		writePEM(f.serverKey, "PRIVATE KEY", serverKeyDER),
+	)
+}
+
+func writePEM(filename, blockType string, der []byte) error {
+	return os.WriteFile(filename, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600)
+}
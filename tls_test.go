@@ -0,0 +1,61 @@
+package pgdevserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_TLS(t *testing.T) {
+	ctx := context.Background()
+	srv := New(Config{
+		PostgresVersion: "17.1.0",
+		CacheDir:        filepath.Join(testCacheDir, "TestServer_TLS"),
+		TLS:             true,
+	})
+	require.NoError(t, srv.Start(ctx))
+	t.Cleanup(func() { require.NoError(t, srv.Stop(ctx)) })
+	u, err := srv.ConnectionURL(ctx)
+	require.NoError(t, err)
+	require.Contains(t, u, "sslmode=verify-full")
+	conn, err := pgx.Connect(ctx, u)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, conn.Close(ctx)) })
+	var ssl bool
+	require.NoError(t, conn.QueryRow(ctx, "SELECT ssl FROM pg_stat_ssl WHERE pid = pg_backend_pid()").Scan(&ssl))
+	require.True(t, ssl)
+}
+
+func Test_tlsFiles_ensure(t *testing.T) {
+	files, err := serverTLSFiles(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, files.ensure())
+
+	caPEM, err := os.ReadFile(files.caCert)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(caPEM))
+	pair, err := tls.LoadX509KeyPair(files.serverCert, files.serverKey)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	require.NoError(t, err)
+	for _, name := range []string{"localhost", "127.0.0.1", "::1"} {
+		_, err = leaf.Verify(x509.VerifyOptions{DNSName: name, Roots: pool})
+		require.NoError(t, err, name)
+	}
+	info, err := os.Stat(files.serverKey)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// existing files are left alone
+	require.NoError(t, files.ensure())
+	got, err := os.ReadFile(files.caCert)
+	require.NoError(t, err)
+	require.Equal(t, caPEM, got)
+}
@@ -0,0 +1,151 @@
+package pgdevserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Upgrade copies the server's data into a new server running the given postgres version using pg_upgrade. The new
+// server keeps this server's name and options, along with parameters set with SetParameter. This server is stopped
+// and left in the cache unchanged. If this server was running, the new server is started.
+func (s *Server) Upgrade(ctx context.Context, version string) (*Server, error) {
+	err := s.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	version, err = s.config.PGManager.ResolveVersion(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+	newCfg := s.Config()
+	newCfg.PostgresVersion = version
+	upgraded := New(newCfg)
+	if upgraded.ID() == s.ID() {
+		return nil, fmt.Errorf("server %s already uses postgres %s", s.ID(), version)
+	}
+	_, unlock, err := upgraded.cache.Dir(upgraded.ID(), validateServerCache, nil)
+	if err == nil {
+		return nil, errors.Join(fmt.Errorf("server %s already exists", upgraded.ID()), unlock())
+	}
+	var wasRunning bool
+	err = s.withCacheLock(ctx, func(oldCacheDir string) error {
+		status, err := s.status(ctx, oldCacheDir)
+		if err != nil {
+			return err
+		}
+		wasRunning = status == StatusRunning
+		err = s.stop(ctx, oldCacheDir)
+		if err != nil {
+			return err
+		}
+		populator := func(newCacheDir string) error {
+			return upgraded.populateFromUpgrade(ctx, s, oldCacheDir, newCacheDir)
+		}
+		_, unlock, err := upgraded.cache.Dir(upgraded.ID(), validateServerCache, populator)
+		if err != nil {
+			return err
+		}
+		return unlock()
+	})
+	if err != nil {
+		return nil, err
+	}
+	if wasRunning {
+		err = upgraded.Start(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return upgraded, nil
+}
+
+// populateFromUpgrade creates a new cluster in newCacheDir and runs pg_upgrade to copy old's data into it.
+func (s *Server) populateFromUpgrade(ctx context.Context, old *Server, oldCacheDir, newCacheDir string) (errOut error) {
+	err := s.populateCache(ctx, newCacheDir)
+	if err != nil {
+		return err
+	}
+	newDataDir := filepath.Join(newCacheDir, "data")
+	// Without a data directory the entry fails validation and is repopulated on next use instead of
+	// looking like an empty server.
+	defer func() {
+		if errOut != nil {
+			errOut = errors.Join(errOut, os.RemoveAll(newDataDir))
+		}
+	}()
+	oldBin, unlockOld, err := old.bin(ctx, oldCacheDir)
+	if err != nil {
+		return err
+	}
+	defer func() { errOut = errors.Join(errOut, unlockOld()) }()
+	newBin, unlockNew, err := s.config.PGManager.Bin(ctx, s.config.PostgresVersion)
+	if err != nil {
+		return err
+	}
+	defer func() { errOut = errors.Join(errOut, unlockNew()) }()
+	oldPort, err := availableTcpPort("")
+	if err != nil {
+		return err
+	}
+	newPort, err := availableTcpPort("")
+	if err != nil {
+		return err
+	}
+	// pg_upgrade writes logs and sockets to its working directory. A short temp path keeps the socket path
+	// under the unix socket length limit.
+	workDir, err := os.MkdirTemp("", "pgdevserver-upgrade")
+	if err != nil {
+		return err
+	}
+	defer func() { errOut = errors.Join(errOut, os.RemoveAll(workDir)) }()
+	absOldDataDir, err := filepath.Abs(filepath.Join(oldCacheDir, "data"))
+	if err != nil {
+		return err
+	}
+	absNewDataDir, err := filepath.Abs(newDataDir)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, filepath.Join(newBin, "pg_upgrade"),
+		"--old-datadir", absOldDataDir,
+		"--new-datadir", absNewDataDir,
+		"--old-bindir", oldBin,
+		"--new-bindir", newBin,
+		"--old-port", oldPort,
+		"--new-port", newPort,
+		"--socketdir", workDir,
+		"--username", s.config.user(),
+	)
+	cmd.Dir = workDir
+	cmd.Env = os.Environ()
+	if s.config.Password != "" {
+		cmd.Env = append(cmd.Env, "PGPASSWORD="+s.config.Password)
+	}
+	err = execRun(cmd)
+	if err != nil {
+		return fmt.Errorf("running pg_upgrade: %w", err)
+	}
+	// pg_upgrade doesn't copy configuration files, which would lose parameters set with SetParameter.
+	err = copyAutoConf(absOldDataDir, absNewDataDir)
+	if err != nil {
+		return err
+	}
+	// the old server's data has already been initialized
+	return clearInitScriptsPending(newCacheDir)
+}
+
+// copyAutoConf copies the parameters written by ALTER SYSTEM from one data directory to another.
+func copyAutoConf(oldDataDir, newDataDir string) error {
+	b, err := os.ReadFile(filepath.Join(oldDataDir, "postgresql.auto.conf"))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	}
+	return os.WriteFile(filepath.Join(newDataDir, "postgresql.auto.conf"), b, 0o600)
+}
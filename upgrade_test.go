@@ -0,0 +1,68 @@
+package pgdevserver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Upgrade(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	ctx := context.Background()
+	srv := New(Config{
+		PostgresVersion: "16.6.0",
+		CacheDir:        t.TempDir(),
+	})
+	require.NoError(t, srv.Start(ctx))
+	u, err := srv.CreateDatabase(ctx, "app", nil)
+	require.NoError(t, err)
+	conn, err := pgx.Connect(ctx, u)
+	require.NoError(t, err)
+	_, err = conn.Exec(ctx, "CREATE TABLE foo (id int); INSERT INTO foo VALUES (1)")
+	require.NoError(t, err)
+	require.NoError(t, conn.Close(ctx))
+	_, err = srv.SetParameter(ctx, "work_mem", "8MB")
+	require.NoError(t, err)
+
+	upgraded, err := srv.Upgrade(ctx, "17.1.0")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, upgraded.Stop(ctx)) })
+	require.Equal(t, srv.Config().Name, upgraded.Config().Name)
+	status, err := srv.Status(ctx)
+	require.NoError(t, err)
+	require.Equal(t, StatusStopped, status)
+
+	u, err = upgraded.DatabaseURL(ctx, "app")
+	require.NoError(t, err)
+	conn, err = pgx.Connect(ctx, u)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, conn.Close(ctx)) })
+	var version, id int
+	require.NoError(t, conn.QueryRow(ctx, "SELECT current_setting('server_version_num')::int / 10000").Scan(&version))
+	require.Equal(t, 17, version)
+	require.NoError(t, conn.QueryRow(ctx, "SELECT id FROM foo").Scan(&id))
+	require.Equal(t, 1, id)
+	var workMem string
+	require.NoError(t, conn.QueryRow(ctx, "SHOW work_mem").Scan(&workMem))
+	require.Equal(t, "8MB", workMem)
+}
+
+func Test_copyAutoConf(t *testing.T) {
+	oldDataDir, newDataDir := t.TempDir(), t.TempDir()
+	require.NoError(t, copyAutoConf(oldDataDir, newDataDir))
+	_, err := os.Stat(filepath.Join(newDataDir, "postgresql.auto.conf"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	conf := "# Do not edit this file manually!\nwork_mem = '8MB'\n"
+	require.NoError(t, os.WriteFile(filepath.Join(oldDataDir, "postgresql.auto.conf"), []byte(conf), 0o600))
+	require.NoError(t, copyAutoConf(oldDataDir, newDataDir))
+	b, err := os.ReadFile(filepath.Join(newDataDir, "postgresql.auto.conf"))
+	require.NoError(t, err)
+	require.Equal(t, conf, string(b))
+}
@@ -0,0 +1,91 @@
+package pgdevserver
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/willabides/pgdevserver/internal"
+)
+
+// latestVersion is the version alias for the newest version of postgres.
+const latestVersion = "latest"
+
+// ResolveVersion resolves a version constraint such as "17", "~16.4", ">=15 <17" or "latest" to an exact postgres
+// version. Installed versions, including system installations, are preferred, then the versions known when this
+// package was built, then versions available from maven. Exact versions like "17.2.0" are returned unchanged.
+func (m *PGManager) ResolveVersion(ctx context.Context, constraint string) (string, error) {
+	m.init()
+	if isExactVersion(constraint) {
+		return constraint, nil
+	}
+	c, err := parseVersionConstraint(constraint)
+	if err != nil {
+		return "", err
+	}
+	installed, err := m.InstalledVersions()
+	if err != nil {
+		return "", err
+	}
+	version := newestMatch(c, installed)
+	if version != "" {
+		return version, nil
+	}
+	if m.config.SystemPostgres == SystemPostgresOnly {
+		return "", fmt.Errorf("no postgres version installed on the system matches %q", constraint)
+	}
+	version = newestMatch(c, knownSystemVersions(runtime.GOOS+"/"+runtime.GOARCH))
+	if version != "" {
+		return version, nil
+	}
+	available, err := m.AvailableVersions(ctx)
+	if err != nil {
+		return "", err
+	}
+	version = newestMatch(c, available)
+	if version == "" {
+		return "", fmt.Errorf("no postgres version matches %q", constraint)
+	}
+	return version, nil
+}
+
+// isExactVersion reports whether version is a complete version like "17.2.0" or "11.10.0-1" rather than a
+// constraint.
+func isExactVersion(version string) bool {
+	_, err := semver.StrictNewVersion(version)
+	return err == nil
+}
+
+func parseVersionConstraint(constraint string) (*semver.Constraints, error) {
+	if constraint == latestVersion {
+		constraint = "*"
+	}
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid postgres version %q: %w", constraint, err)
+	}
+	return c, nil
+}
+
+// newestMatch returns the newest of versions that satisfies c or "" if none do. Repackaged builds like 11.10.0-1
+// look like prereleases to semver, so versions are checked without their suffix.
+func newestMatch(c *semver.Constraints, versions []string) string {
+	var matches []string
+	for _, version := range versions {
+		v, err := semver.NewVersion(version)
+		if err != nil {
+			continue
+		}
+		core, err := v.SetPrerelease("")
+		if err != nil || !c.Check(&core) {
+			continue
+		}
+		matches = append(matches, version)
+	}
+	if len(matches) == 0 {
+		return ""
+	}
+	internal.SortVersions(matches)
+	return matches[len(matches)-1]
+}
@@ -0,0 +1,142 @@
+package pgdevserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_ResolveVersion(t *testing.T) {
+	cacheDir := t.TempDir()
+	mgr := testMgr(t, cacheDir)
+	for _, version := range []string{"16.4.0", "17.1.0"} {
+		filename := versionFile(filepath.Join(cacheDir, pgCacheKey(version)))
+		require.NoError(t, os.MkdirAll(filepath.Dir(filename), 0o700))
+		require.NoError(t, os.WriteFile(filename, []byte(version+"\n"), 0o600))
+	}
+	resolve := func(t *testing.T, constraint string) string {
+		t.Helper()
+		version, err := mgr.ResolveVersion(t.Context(), constraint)
+		require.NoError(t, err)
+		return version
+	}
+
+	t.Run("exact", func(t *testing.T) {
+		require.Equal(t, "17.2.0", resolve(t, "17.2.0"))
+		require.Equal(t, "11.10.0-1", resolve(t, "11.10.0-1"))
+	})
+
+	t.Run("prefers installed", func(t *testing.T) {
+		require.Equal(t, "17.1.0", resolve(t, "17"))
+		require.Equal(t, "17.1.0", resolve(t, "latest"))
+		require.Equal(t, "16.4.0", resolve(t, ">=15 <17"))
+	})
+
+	t.Run("known versions", func(t *testing.T) {
+		require.True(t, strings.HasPrefix(resolve(t, "~15.2"), "15.2."))
+		require.True(t, strings.HasPrefix(resolve(t, "13"), "13."))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := mgr.ResolveVersion(t.Context(), "not a version")
+		require.Error(t, err)
+	})
+}
+
+func Test_newestMatch(t *testing.T) {
+	match := func(constraint string, versions ...string) string {
+		c, err := parseVersionConstraint(constraint)
+		require.NoError(t, err)
+		return newestMatch(c, versions)
+	}
+	require.Equal(t, "11.10.0", match("~11.10", "11.9.0", "11.10.0-1", "11.10.0"))
+	require.Equal(t, "12.5.0-1", match("12", "12.5.0-1", "13.1.0"))
+	require.Equal(t, "13.1.0", match("latest", "12.5.0-1", "13.1.0", "bogus"))
+	require.Equal(t, "", match(">=18", "12.5.0-1", "13.1.0"))
+}
+
+func TestServer_resolvesVersion(t *testing.T) {
+	srv := New(Config{
+		PostgresVersion: "17",
+		CacheDir:        t.TempDir(),
+	})
+	version := srv.Config().PostgresVersion
+	require.True(t, isExactVersion(version))
+	require.Equal(t, uint64(17), semver.MustParse(version).Major())
+	pinned := New(Config{
+		PostgresVersion: version,
+		CacheDir:        t.TempDir(),
+	})
+	require.Equal(t, pinned.ID(), srv.ID())
+}
+
+func TestServer_resolvesVersion_existing(t *testing.T) {
+	cacheDir := t.TempDir()
+	// 17.1.0 is installed after a server was created with 16.4.0
+	filename := versionFile(filepath.Join(cacheDir, "postgres", pgCacheKey("17.1.0")))
+	require.NoError(t, os.MkdirAll(filepath.Dir(filename), 0o700))
+	require.NoError(t, os.WriteFile(filename, []byte("17.1.0\n"), 0o600))
+	existing := fakeServerCache(t, cacheDir, Config{PostgresVersion: "16.4.0", Name: "existing"}, time.Now())
+
+	version := func(cfg Config) string {
+		t.Helper()
+		cfg.CacheDir = cacheDir
+		srv := New(cfg)
+		require.NoError(t, srv.Err())
+		return srv.Config().PostgresVersion
+	}
+	srv := New(Config{PostgresVersion: "latest", Name: "existing", CacheDir: cacheDir})
+	require.Equal(t, existing.ID(), srv.ID())
+	require.Equal(t, "16.4.0", version(Config{PostgresVersion: "16", Name: "existing"}))
+	require.Equal(t, "17.1.0", version(Config{PostgresVersion: "17", Name: "existing"}))
+	require.Equal(t, "17.1.0", version(Config{PostgresVersion: "latest", Name: "other"}))
+	require.Equal(t, "17.1.0", version(Config{
+		PostgresVersion: "latest",
+		Name:            "existing",
+		PostgresOptions: []string{"-c work_mem=8MB"},
+	}))
+}
+
+func TestServer_Err(t *testing.T) {
+	srv := New(Config{PostgresVersion: "not a version", CacheDir: t.TempDir()})
+	require.ErrorContains(t, srv.Err(), "invalid postgres version")
+	require.Empty(t, srv.ID())
+	require.ErrorIs(t, srv.Start(t.Context()), srv.Err())
+}
+
+func TestServer_resolvesVersion_lazily(t *testing.T) {
+	var requests atomic.Int32
+	repo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, err := w.Write([]byte(`<metadata><versioning><versions>
+			<version>99.1.0</version>
+		</versions></versioning></metadata>`))
+		assert.NoError(t, err)
+	}))
+	t.Cleanup(repo.Close)
+	cacheDir := t.TempDir()
+	pgm := NewPGManager(PGMConfig{CacheDir: filepath.Join(cacheDir, "postgres"), MavenURLs: []string{repo.URL}})
+
+	srv := New(Config{PostgresVersion: ">=99", CacheDir: cacheDir, PGManager: pgm})
+	require.Zero(t, requests.Load())
+
+	// a canceled context isn't the final word on the version
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err := srv.Status(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+
+	require.NoError(t, srv.Err())
+	require.Equal(t, "99.1.0", srv.Config().PostgresVersion)
+	require.NotZero(t, requests.Load())
+}